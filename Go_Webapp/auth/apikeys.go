@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+)
+
+// APIKeyPrefix marks our keys so the middleware can tell them apart from JWTs in a Bearer header
+const APIKeyPrefix = "wak_"
+
+var (
+	// ErrInvalidAPIKey covers unknown, revoked and malformed keys
+	ErrInvalidAPIKey = errors.New("auth: invalid api key")
+
+	// ErrTooManyAPIKeys is returned once a user holds API_KEY_MAX_PER_USER active keys
+	ErrTooManyAPIKeys = errors.New("auth: api key limit reached")
+)
+
+// lastUsedResolution limits last_used_at writes to one per key per minute
+const lastUsedResolution = time.Minute
+
+// IsAPIKey reports whether a credential has the API key format
+func IsAPIKey(raw string) bool {
+	return strings.HasPrefix(raw, APIKeyPrefix)
+}
+
+// CreateAPIKey mints a key for the user. The raw key is returned once and never stored.
+// Scopes must already be validated by the caller.
+func CreateAPIKey(user *models.User, name string, scopes []string) (*models.APIKey, string, error) {
+	var active int64
+	if err := db.DB.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", user.ID).Count(&active).Error; err != nil {
+		return nil, "", err
+	}
+	if active >= int64(env.Int("API_KEY_MAX_PER_USER", 10)) {
+		return nil, "", ErrTooManyAPIKeys
+	}
+
+	token, err := NewRandomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	raw := APIKeyPrefix + token
+
+	key := models.APIKey{
+		UserID:  user.ID,
+		Name:    name,
+		Prefix:  raw[:len(APIKeyPrefix)+8],
+		KeyHash: HashToken(raw),
+		Scopes:  strings.Join(scopes, ","),
+	}
+	if err := db.DB.Create(&key).Error; err != nil {
+		return nil, "", err
+	}
+	return &key, raw, nil
+}
+
+// AuthenticateAPIKey resolves a raw key to its owner and records when it was last used
+func AuthenticateAPIKey(raw string) (*models.User, *models.APIKey, error) {
+	if !IsAPIKey(raw) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	if err := db.DB.Where("key_hash = ? AND revoked_at IS NULL", HashToken(raw)).First(&key).Error; err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, key.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	// Best effort: a failed timestamp write must not fail the request
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := db.DB.Model(&models.APIKey{}).Where("id = ?", key.ID).Update("last_used_at", now).Error; err != nil {
+			logs.Warn("Failed to record api key use: " + err.Error())
+		}
+		key.LastUsedAt = &now
+	}
+
+	return &user, &key, nil
+}
+
+// ListAPIKeys returns the user's keys, newest first, including revoked ones
+func ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := db.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey revokes one of the user's keys. It reports false if the key does not exist.
+func RevokeAPIKey(userID, keyID uint) (bool, error) {
+	result := db.DB.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAllAPIKeys revokes every key of a user. Called when the password is changed or reset,
+// so keys minted by whoever knew the old password stop working.
+func RevokeAllAPIKeys(userID uint) error {
+	return db.DB.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
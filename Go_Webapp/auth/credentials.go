@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+
+	"my-project/db"
+	"my-project/logs"
+	"my-project/models"
+)
+
+// ErrInvalidCredentials is returned for an unknown username or a wrong password.
+// Both cases share one error so callers cannot leak which one happened.
+var ErrInvalidCredentials = errors.New("auth: invalid credentials")
+
+// CheckCredentials looks up the user and verifies the password, plus mfaCode for
+// accounts with MFA enabled. Used by the token endpoint.
+// Returns a *LockedError while the username or clientIP is locked out, and
+// ErrEmailNotVerified / ErrMFARequired only after the password matched, so they cannot be used to probe accounts.
+func CheckCredentials(username, password, mfaCode, clientIP string) (*models.User, error) {
+	return checkCredentials(username, password, mfaCode, clientIP, false)
+}
+
+// CheckBasicCredentials is CheckCredentials for the Basic Auth middleware. Accounts with MFA
+// enabled get ErrMFABasicAuth (again only after the password matched) instead of a code check.
+func CheckBasicCredentials(username, password, clientIP string) (*models.User, error) {
+	return checkCredentials(username, password, "", clientIP, true)
+}
+
+func checkCredentials(username, password, mfaCode, clientIP string, basic bool) (*models.User, error) {
+	// 1. Lockout Check (before bcrypt, so locked keys cost nothing)
+	keys := []string{userKey(username), ipKey(clientIP)}
+	if err := checkLockout(keys...); err != nil {
+		logs.Info("Rejected locked out login for user: " + username)
+		return nil, err
+	}
+
+	// 2. Find User in DB
+	var user models.User
+	if err := db.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		logs.Info("Cannot find User: " + username)
+		recordFailure(keys...)
+		return nil, ErrInvalidCredentials
+	}
+
+	// 3. Compare Password (bcrypt or argon2id, detected from the stored hash)
+	ok, needsRehash, err := VerifyPassword(user.Password, password)
+	if err != nil {
+		logs.Error("Password verification failed for user " + username + ": " + err.Error())
+	}
+	if !ok {
+		logs.Info("Password does not match for user: " + username)
+		recordFailure(keys...)
+		return nil, ErrInvalidCredentials
+	}
+
+	// 4. Second Factor (wrong codes count towards the lockout like wrong passwords)
+	if basic && user.MFAEnabled {
+		logs.Info("Rejected basic auth for mfa user: " + username)
+		return nil, ErrMFABasicAuth
+	}
+	if err := verifySecondFactor(&user, mfaCode); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			logs.Info("Invalid mfa code for user: " + username)
+			recordFailure(keys...)
+		}
+		return nil, err
+	}
+	recordSuccess(username)
+
+	// 5. Transparent Upgrade: we only see the plaintext now, so rehash with the current algorithm
+	if needsRehash {
+		rehashPassword(&user, password)
+	}
+
+	// 6. Email Verification
+	if err := EnsureVerified(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// rehashPassword stores a new hash of password. Failures are logged only: the login itself succeeded.
+func rehashPassword(user *models.User, password string) {
+	newHash, err := HashPassword(password)
+	if err != nil {
+		logs.Error("Password rehash failed: " + err.Error())
+		return
+	}
+
+	if err := db.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("password", newHash).Error; err != nil {
+		logs.Error("Failed to store rehashed password: " + err.Error())
+		return
+	}
+
+	user.Password = newHash
+	logs.Info("Upgraded password hash for user: " + user.Username)
+	logs.Client.Increment("auth.password.rehash")
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+)
+
+// LockedError is returned while a username or client IP is temporarily locked out
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("auth: locked out, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// threshold returns how many consecutive failures a key may have before it is locked.
+// IPs get a higher limit because many users can share one (NAT, corporate proxies).
+func threshold(key string) int {
+	if strings.HasPrefix(key, "ip:") {
+		return env.Int("LOCKOUT_IP_THRESHOLD", 20)
+	}
+	return env.Int("LOCKOUT_THRESHOLD", 5)
+}
+
+// lockDuration doubles with every failure past the threshold (LOCKOUT_BASE, capped at LOCKOUT_MAX)
+func lockDuration(failures, limit int) time.Duration {
+	base := env.Duration("LOCKOUT_BASE", 30*time.Second)
+	max := env.Duration("LOCKOUT_MAX", time.Hour)
+
+	exponent := failures - limit
+	if exponent > 30 {
+		return max
+	}
+	lock := time.Duration(float64(base) * math.Pow(2, float64(exponent)))
+	if lock > max {
+		return max
+	}
+	return lock
+}
+
+// checkLockout returns a LockedError if any of the keys is currently locked
+func checkLockout(keys ...string) error {
+	var attempts []models.LoginAttempt
+	if err := db.DB.Where("key IN ? AND locked_until > ?", keys, time.Now()).Find(&attempts).Error; err != nil {
+		// Fail open: a broken counter table must not lock everybody out
+		logs.Error("Lockout lookup failed: " + err.Error())
+		return nil
+	}
+
+	var retryAfter time.Duration
+	for _, attempt := range attempts {
+		if remaining := time.Until(*attempt.LockedUntil); remaining > retryAfter {
+			retryAfter = remaining
+		}
+	}
+	if retryAfter > 0 {
+		logs.Client.Increment("auth.login.blocked")
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordFailure bumps the counters and locks keys that crossed their threshold.
+// Counters restart when the previous failure is older than LOCKOUT_RESET_AFTER.
+func recordFailure(keys ...string) {
+	logs.Client.Increment("auth.login.failure")
+
+	now := time.Now()
+	resetBefore := now.Add(-env.Duration("LOCKOUT_RESET_AFTER", time.Hour))
+
+	for _, key := range keys {
+		var failures int
+		err := db.DB.Raw(`
+			INSERT INTO login_attempts (key, failures, last_failure_at)
+			VALUES (?, 1, ?)
+			ON CONFLICT (key) DO UPDATE SET
+				failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+				last_failure_at = EXCLUDED.last_failure_at
+			RETURNING failures`, key, now, resetBefore).Scan(&failures).Error
+		if err != nil {
+			logs.Error("Failed to record login failure: " + err.Error())
+			continue
+		}
+
+		limit := threshold(key)
+		if failures < limit {
+			continue
+		}
+
+		lockedUntil := now.Add(lockDuration(failures, limit))
+		if err := db.DB.Model(&models.LoginAttempt{}).Where("key = ?", key).Update("locked_until", lockedUntil).Error; err != nil {
+			logs.Error("Failed to lock key: " + err.Error())
+			continue
+		}
+		logs.Warn("Locked out " + key + " until " + lockedUntil.Format(time.RFC3339))
+		logs.Client.Increment("auth.login.lockout")
+	}
+}
+
+// recordSuccess clears the username counter. The IP counter is left alone so an attacker
+// cannot reset it by interleaving logins to an account they own.
+func recordSuccess(username string) {
+	if err := db.DB.Where("key = ?", userKey(username)).Delete(&models.LoginAttempt{}).Error; err != nil {
+		logs.Error("Failed to reset login failures: " + err.Error())
+	}
+}
+
+// Unlock clears the failure counter and any lock on a username (admin action)
+func Unlock(username string) error {
+	return db.DB.Where("key = ?", userKey(username)).Delete(&models.LoginAttempt{}).Error
+}
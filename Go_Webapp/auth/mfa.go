@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+)
+
+// recoveryCodeCount is how many recovery codes a confirmed enrollment hands out
+const recoveryCodeCount = 10
+
+var (
+	// ErrMFARequired means the password was right but the account needs a second factor
+	ErrMFARequired = errors.New("mfa code required")
+
+	// ErrMFABasicAuth means Basic credentials were sent for an account with MFA. Basic Auth resends the
+	// credentials on every request and a code is accepted once, so these clients must use tokens.
+	ErrMFABasicAuth = errors.New("basic auth is not available with mfa enabled, exchange credentials and code at /v1/auth/token and use the bearer token")
+
+	// ErrInvalidMFACode covers wrong, expired, replayed and already used codes
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+
+	// ErrMFAAlreadyEnabled is returned when enrolling an account that already has MFA
+	ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+
+	// ErrMFANotEnrolled is returned when confirming without a pending enrollment
+	ErrMFANotEnrolled = errors.New("mfa enrollment has not been started")
+)
+
+// BeginMFAEnrollment stores a fresh pending secret and returns it with its otpauth URI.
+// MFA stays off until ConfirmMFAEnrollment sees a valid code, so a half-finished
+// enrollment can never lock the user out.
+func BeginMFAEnrollment(user *models.User) (secret string, uri string, err error) {
+	if user.MFAEnabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err = NewTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	updates := map[string]interface{}{"mfa_secret": secret, "mfa_last_step": 0}
+	if err := db.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		return "", "", err
+	}
+	user.MFASecret = secret
+
+	return secret, TOTPURI(env.String("MFA_ISSUER", "webapp"), user.Username, secret), nil
+}
+
+// ConfirmMFAEnrollment enables MFA once the user proves their authenticator works,
+// and returns a new set of recovery codes (shown once, stored hashed).
+func ConfirmMFAEnrollment(user *models.User, code string) ([]string, error) {
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.MFASecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+	if !acceptTOTP(user, code) {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.MFARecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = raw
+		records[i] = models.MFARecoveryCode{UserID: user.ID, CodeHash: HashToken(normalizeRecoveryCode(raw))}
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Update("mfa_enabled", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.MFAEnabled = true
+	logs.Info("MFA enabled for user: " + user.Username)
+	return codes, nil
+}
+
+// DisableMFA turns MFA off and discards the secret and recovery codes. It needs a current code or an
+// unused recovery code like a login does, so an access token alone cannot remove the second factor;
+// wrong codes count towards the user's lockout.
+func DisableMFA(user *models.User, code string) error {
+	keys := []string{userKey(user.Username)}
+	if err := checkLockout(keys...); err != nil {
+		return err
+	}
+	if err := verifySecondFactor(user, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			logs.Info("Invalid mfa code to disable mfa for user: " + user.Username)
+			recordFailure(keys...)
+		}
+		return err
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"mfa_enabled": false, "mfa_secret": "", "mfa_last_step": 0}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	user.MFAEnabled = false
+	user.MFASecret = ""
+	logs.Info("MFA disabled for user: " + user.Username)
+	return nil
+}
+
+// verifySecondFactor accepts a current TOTP code or an unused recovery code
+func verifySecondFactor(user *models.User, code string) error {
+	if !user.MFAEnabled {
+		return nil
+	}
+	if code == "" {
+		return ErrMFARequired
+	}
+
+	if acceptTOTP(user, strings.TrimSpace(code)) {
+		return nil
+	}
+
+	// Recovery codes are single use: the conditional update is the atomic consume
+	result := db.DB.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		logs.Error("Recovery code lookup failed: " + result.Error.Error())
+		return ErrInvalidMFACode
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+
+	logs.Warn("Recovery code used for user: " + user.Username)
+	logs.Client.Increment("auth.mfa.recovery_code")
+	return nil
+}
+
+// acceptTOTP checks the code and records its time step, so each code works only once
+func acceptTOTP(user *models.User, code string) bool {
+	step := matchTOTP(user.MFASecret, code, time.Now())
+	if step == 0 {
+		return false
+	}
+
+	result := db.DB.Model(&models.User{}).
+		Where("id = ? AND mfa_last_step < ?", user.ID, step).
+		Update("mfa_last_step", step)
+	if result.Error != nil {
+		logs.Error("Failed to record mfa step: " + result.Error.Error())
+		return false
+	}
+	if result.RowsAffected == 0 {
+		logs.Warn("Replayed mfa code for user: " + user.Username)
+		return false
+	}
+
+	user.MFALastStep = step
+	return true
+}
+
+// newRecoveryCode returns a code like "k3p7q-x9m2a" (50 random bits)
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	raw := strings.ToLower(base32.StdEncoding.EncodeToString(buf))[:10]
+	return raw[:5] + "-" + raw[5:], nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and the dash users may or may not type
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"my-project/env"
+)
+
+const (
+	// MinPasswordLength and MaxPasswordLength bound every password we hash.
+	// 72 bytes is bcrypt's hard limit, so passwords stay portable between algorithms.
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// ErrUnknownHashFormat is returned for stored hashes no registered Hasher recognises
+var ErrUnknownHashFormat = errors.New("auth: unknown password hash format")
+
+// Hasher is one password hashing algorithm. Encoded hashes are self-describing
+// (algorithm + parameters + salt), so several versions can coexist in the users table.
+type Hasher interface {
+	// Name is the value of PASSWORD_HASH_ALGORITHM selecting this hasher
+	Name() string
+
+	// Hash returns the encoded hash of password with the configured parameters
+	Hash(password string) (string, error)
+
+	// Matches reports whether encoded was produced by this hasher
+	Matches(encoded string) bool
+
+	// Verify compares password against encoded
+	Verify(encoded, password string) (bool, error)
+
+	// NeedsRehash reports whether encoded used weaker/different parameters than configured
+	NeedsRehash(encoded string) bool
+}
+
+// --- bcrypt ---
+
+type bcryptHasher struct {
+	cost int
+}
+
+func (h bcryptHasher) Name() string { return "bcrypt" }
+
+func (h bcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	return string(bytes), err
+}
+
+func (h bcryptHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h bcryptHasher) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
+
+// --- argon2id ---
+
+var (
+	argon2SlotsOnce sync.Once
+	argon2Slots     chan struct{}
+)
+
+// argon2Slot bounds concurrent argon2id computations (ARGON2_MAX_CONCURRENT, default one per CPU).
+// Every Basic-auth request verifies a password, so without a bound a burst of requests could
+// allocate ARGON2_MEMORY_KIB each at once. Call the returned function to release the slot.
+func argon2Slot() func() {
+	argon2SlotsOnce.Do(func() {
+		argon2Slots = make(chan struct{}, max(1, env.Int("ARGON2_MAX_CONCURRENT", runtime.NumCPU())))
+	})
+	argon2Slots <- struct{}{}
+	return func() { <-argon2Slots }
+}
+
+type argon2idHasher struct {
+	memory      uint32 // KiB
+	iterations  uint32
+	parallelism uint8
+	saltLength  int
+	keyLength   uint32
+}
+
+func (h argon2idHasher) Name() string { return "argon2id" }
+
+// Hash encodes in the PHC string format: $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+func (h argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	release := argon2Slot()
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, h.keyLength)
+	release()
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.memory, h.iterations, h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h argon2idHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+// decode parses an encoded hash back into its parameters, salt and key
+func (h argon2idHasher) decode(encoded string) (argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return h, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return h, nil, nil, ErrUnknownHashFormat
+	}
+
+	var params argon2idHasher
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return h, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return h, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return h, nil, nil, ErrUnknownHashFormat
+	}
+	params.saltLength = len(salt)
+	params.keyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+func (h argon2idHasher) Verify(encoded, password string) (bool, error) {
+	params, salt, key, err := h.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	release := argon2Slot()
+	candidate := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, params.keyLength)
+	release()
+
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+func (h argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := h.decode(encoded)
+	if err != nil {
+		return true
+	}
+	return params.memory != h.memory || params.iterations != h.iterations ||
+		params.parallelism != h.parallelism || params.keyLength != h.keyLength
+}
+
+// --- Registry ---
+
+// hashers returns every supported algorithm with its configured parameters.
+// The argon2id defaults (19 MiB, 2 passes, 1 lane) are OWASP's baseline: passwords are verified
+// on every Basic-auth request, so the cost is paid per request, not just per login.
+// Hashes made with other parameters are upgraded on the next successful login.
+func hashers() []Hasher {
+	return []Hasher{
+		argon2idHasher{
+			memory:      uint32(env.Int("ARGON2_MEMORY_KIB", 19*1024)),
+			iterations:  uint32(env.Int("ARGON2_ITERATIONS", 2)),
+			parallelism: uint8(env.Int("ARGON2_PARALLELISM", 1)),
+			saltLength:  16,
+			keyLength:   32,
+		},
+		bcryptHasher{cost: env.Int("BCRYPT_COST", 12)},
+	}
+}
+
+// currentHasher is the algorithm new hashes are produced with (PASSWORD_HASH_ALGORITHM, default argon2id)
+func currentHasher() Hasher {
+	name := env.String("PASSWORD_HASH_ALGORITHM", "argon2id")
+	for _, hasher := range hashers() {
+		if hasher.Name() == name {
+			return hasher
+		}
+	}
+	return hashers()[0]
+}
+
+// HashPassword hashes with the current algorithm and parameters
+func HashPassword(password string) (string, error) {
+	return currentHasher().Hash(password)
+}
+
+// VerifyPassword checks password against a stored hash of any supported algorithm.
+// needsRehash is true when the hash should be upgraded to the current algorithm/parameters.
+func VerifyPassword(encoded, password string) (ok bool, needsRehash bool, err error) {
+	current := currentHasher()
+
+	for _, hasher := range hashers() {
+		if !hasher.Matches(encoded) {
+			continue
+		}
+
+		ok, err := hasher.Verify(encoded, password)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, hasher.Name() != current.Name() || current.NeedsRehash(encoded), nil
+	}
+
+	return false, false, ErrUnknownHashFormat
+}
@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+)
+
+const tokenIssuer = "webapp"
+
+var (
+	// ErrInvalidAccessToken covers bad signatures, expired tokens and wrong token types
+	ErrInvalidAccessToken = errors.New("auth: invalid access token")
+
+	// ErrInvalidRefreshToken covers unknown, expired, revoked and reused refresh tokens
+	ErrInvalidRefreshToken = errors.New("auth: invalid refresh token")
+)
+
+// TokenPair is the response body of POST /v1/auth/token
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AccessClaims are the claims carried by a signed access token
+type AccessClaims struct {
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the numeric user ID stored in the "sub" claim
+func (c *AccessClaims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 32)
+	return uint(id), err
+}
+
+var (
+	secretOnce sync.Once
+	secret     []byte
+)
+
+// InitializeSigningKey loads JWT_SECRET at startup, so a missing secret stops the server
+// instead of surfacing on the first login.
+func InitializeSigningKey() {
+	signingKey()
+}
+
+// signingKey reads JWT_SECRET once. Outside development and tests (see env.IsDev) it must be set.
+// In development a random key is generated, which invalidates tokens on restart.
+func signingKey() []byte {
+	secretOnce.Do(func() {
+		if value := env.String("JWT_SECRET", ""); value != "" {
+			secret = []byte(value)
+			return
+		}
+		if !env.IsDev() {
+			logs.Fatal("JWT_SECRET is not set (required unless GO_ENV is test or development)")
+		}
+		logs.Warn("JWT_SECRET is not set, using a random per-process signing key")
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	})
+	return secret
+}
+
+func accessTokenTTL() time.Duration {
+	return env.Duration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+func refreshTokenTTL() time.Duration {
+	return env.Duration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// HashToken returns the hex SHA-256 of a high-entropy random token.
+// A plain hash is enough here because the token itself is 256 random bits.
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRandomToken returns 32 random bytes encoded as URL-safe base64
+func NewRandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// ParseAccessToken verifies the signature, issuer and expiry of an access token
+func ParseAccessToken(raw string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		return signingKey(), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	return claims, nil
+}
+
+// IssueTokenPair signs a new access token and starts a new refresh token family
+func IssueTokenPair(user *models.User) (*TokenPair, error) {
+	return issueTokenPair(db.DB, user, uuid.New().String())
+}
+
+func issueTokenPair(tx *gorm.DB, user *models.User, familyID string) (*TokenPair, error) {
+	now := time.Now()
+	ttl := accessTokenTTL()
+
+	// 1. Sign Access Token
+	claims := AccessClaims{
+		Username: user.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			ID:        uuid.New().String(),
+		},
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(signingKey())
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Store hashed Refresh Token
+	refreshToken, err := NewRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: HashToken(refreshToken),
+		ExpiresAt: now.Add(refreshTokenTTL()),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(ttl.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new pair.
+// The presented token is revoked; presenting an already revoked token is treated
+// as theft and revokes every token in its family.
+func RotateRefreshToken(raw string) (*models.User, *TokenPair, error) {
+	var user models.User
+	var pair *TokenPair
+	reused := false
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the token row so two concurrent refreshes cannot both succeed
+		var record models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", HashToken(raw)).First(&record).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		// 2. Reuse Detection
+		if record.RevokedAt != nil {
+			reused = true
+			return ErrInvalidRefreshToken
+		}
+		if record.ExpiresAt.Before(time.Now()) {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, record.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		// 3. Revoke the presented token and issue its successor in the same family
+		if err := tx.Model(&record).Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		var err error
+		pair, err = issueTokenPair(tx, &user, record.FamilyID)
+		return err
+	})
+
+	if reused {
+		logs.Warn("Refresh token reuse detected, revoking token family")
+		RevokeRefreshToken(raw)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, pair, nil
+}
+
+// RevokeRefreshToken revokes the whole family the token belongs to.
+// Unknown tokens are ignored, as recommended by RFC 7009.
+func RevokeRefreshToken(raw string) error {
+	var record models.RefreshToken
+	if err := db.DB.Where("token_hash = ?", HashToken(raw)).First(&record).Error; err != nil {
+		return nil
+	}
+
+	return db.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", record.FamilyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllRefreshTokens revokes every outstanding refresh token of a user
+func RevokeAllRefreshTokens(userID uint) error {
+	return db.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"errors"
+
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+)
+
+// ErrEmailNotVerified is returned when an unverified account tries to authenticate
+var ErrEmailNotVerified = errors.New("email address has not been verified")
+
+// EnsureVerified rejects unverified accounts.
+// EMAIL_VERIFICATION_MODE=grace lets them through with a warning, for test environments
+// where nobody can click the SNS-delivered link.
+func EnsureVerified(user *models.User) error {
+	if user.Verified {
+		return nil
+	}
+
+	if env.String("EMAIL_VERIFICATION_MODE", "enforce") == "grace" {
+		logs.Warn("Allowing unverified user in grace mode: " + user.Username)
+		return nil
+	}
+
+	logs.Info("Rejecting unverified user: " + user.Username)
+	return ErrEmailNotVerified
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"my-project/auth"
+	"my-project/db"
+	"my-project/jobs"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/policy"
+)
+
+// --- Request Structs ---
+
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// --- Controllers ---
+
+// UnlockUser clears the failed-login counter and lockout of a username
+func UnlockUser(c *gin.Context) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	authUser := authUserInterface.(*models.User)
+
+	username := strings.ToLower(c.Param("username"))
+	if username == "" || len(c.Request.URL.Query()) > 0 || c.Request.ContentLength > 0 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := auth.Unlock(username); err != nil {
+		logs.Error("Unlock failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	logs.Info("User " + username + " unlocked by admin " + authUser.Username)
+	c.Status(http.StatusNoContent)
+}
+
+// UpdateUserRole assigns one of the roles defined in the policy package
+func UpdateUserRole(c *gin.Context) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	authUser := authUserInterface.(*models.User)
+
+	userIdInt, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil || !isValidRequest(c, true) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateRoleRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || !policy.ValidRole(req.Role) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// Admins cannot demote themselves, so there is always a way back in
+	if uint(userIdInt) == authUser.ID && req.Role != policy.RoleAdmin {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	result := db.DB.Model(&models.User{}).Where("id = ?", userIdInt).Update("role", req.Role)
+	if result.Error != nil {
+		logs.Error("Role update failed: " + result.Error.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	logs.Info("User " + strconv.FormatUint(userIdInt, 10) + " given role " + req.Role + " by admin " + authUser.Username)
+	c.Status(http.StatusNoContent)
+}
+
+// RetryAccountDeletion re-queues an account deletion whose storage cleanup ran out of attempts
+func RetryAccountDeletion(c *gin.Context) {
+	if !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	err := jobs.RetryAccountDeletion(c.Param("deletionId"))
+	if errors.Is(err, jobs.ErrDeletionNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logs.Error("Account deletion retry failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
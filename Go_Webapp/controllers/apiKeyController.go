@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"my-project/auth"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/policy"
+)
+
+// --- Request Structs ---
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeyResponse is how a key is shown to its owner. Key is only set on creation.
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	Key        string     `json:"key,omitempty"`
+}
+
+func newAPIKeyResponse(key *models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
+
+// --- Controllers ---
+
+// CreateAPIKey mints a named, scoped key for the authenticated user.
+// The raw key is in the response body once and cannot be retrieved again.
+func CreateAPIKey(c *gin.Context) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	authUser := authUserInterface.(*models.User)
+
+	// 1. Strict Validation
+	if !isValidRequest(c, true) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 || len(req.Scopes) == 0 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// 2. Scopes must be delegable and granted by the user's own role
+	seen := map[string]bool{}
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		if !policy.ValidScope(scope) {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		if !policy.Allows(authUser, policy.Permission(scope)) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	// --- DB: Create API Key (Timer) ---
+	startCreate := time.Now()
+
+	key, raw, err := auth.CreateAPIKey(authUser, req.Name, scopes)
+
+	createDurationMs := float64(time.Since(startCreate).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(createDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.createApiKey", createDurationMs)
+
+	if errors.Is(err, auth.ErrTooManyAPIKeys) {
+		c.Status(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logs.Error("API key creation failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	logs.Info("API key " + key.Prefix + " created for user: " + authUser.Username)
+	response := newAPIKeyResponse(key)
+	response.Key = raw
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetAllAPIKey lists the authenticated user's keys without their secrets
+func GetAllAPIKey(c *gin.Context) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	authUser := authUserInterface.(*models.User)
+
+	if !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// --- DB: Find API Keys (Timer) ---
+	startFind := time.Now()
+
+	keys, err := auth.ListAPIKeys(authUser.ID)
+
+	findDurationMs := float64(time.Since(startFind).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(findDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.findAllApiKeys", findDurationMs)
+
+	if err != nil {
+		logs.Error("API key lookup failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	response := make([]APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		response = append(response, newAPIKeyResponse(&keys[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteAPIKey revokes one of the authenticated user's keys
+func DeleteAPIKey(c *gin.Context) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	authUser := authUserInterface.(*models.User)
+
+	keyId, err := strconv.ParseUint(c.Param("keyId"), 10, 32)
+	if err != nil || !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// --- DB: Revoke API Key (Timer) ---
+	startRevoke := time.Now()
+
+	found, err := auth.RevokeAPIKey(authUser.ID, uint(keyId))
+
+	revokeDurationMs := float64(time.Since(startRevoke).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(revokeDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.revokeApiKey", revokeDurationMs)
+
+	if err != nil {
+		logs.Error("API key revoke failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+	if !found {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"my-project/auth"
+	"my-project/logs"
+)
+
+// --- Request Structs ---
+
+// TokenRequest supports the "password" and "refresh_token" grants
+type TokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	RefreshToken string `json:"refresh_token"`
+
+	// Required with the password grant when the account has MFA enabled
+	MFACode string `json:"mfa_code"`
+}
+
+type RevokeRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// --- Controllers ---
+
+// CreateToken exchanges credentials (or a refresh token) for an access + refresh token pair
+func CreateToken(c *gin.Context) {
+	// 1. Strict Validation
+	if !isValidRequest(c, true) || c.GetHeader("Authorization") != "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var req TokenRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// --- Token: Issue (Timer) ---
+	startIssue := time.Now()
+
+	var pair *auth.TokenPair
+	switch req.GrantType {
+	case "password":
+		if req.Username == "" || req.Password == "" {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		user, err := auth.CheckCredentials(req.Username, req.Password, req.MFACode, c.ClientIP())
+		var locked *auth.LockedError
+		if errors.As(err, &locked) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			c.Status(http.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, auth.ErrMFARequired) || errors.Is(err, auth.ErrInvalidMFACode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+
+		pair, err = auth.IssueTokenPair(user)
+		if err != nil {
+			logs.Error("Token issue failed: " + err.Error())
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+
+	case "refresh_token":
+		if req.RefreshToken == "" {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		_, pair, err = auth.RotateRefreshToken(req.RefreshToken)
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			logs.Error("Token refresh failed: " + err.Error())
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+
+	default:
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	issueDurationMs := float64(time.Since(startIssue).Milliseconds())
+	logs.Info("Token issued in " + strconv.FormatFloat(issueDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("auth.token.latency", issueDurationMs)
+
+	c.JSON(http.StatusOK, pair)
+}
+
+// RevokeToken revokes a refresh token and every token rotated from it
+func RevokeToken(c *gin.Context) {
+	if !isValidRequest(c, true) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var req RevokeRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || req.RefreshToken == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := auth.RevokeRefreshToken(req.RefreshToken); err != nil {
+		logs.Error("Token revoke failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
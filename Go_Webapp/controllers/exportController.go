@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"my-project/env"
+	"my-project/jobs"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/storage"
+)
+
+// DataExportResponse adds a fresh, time-limited download link to completed exports
+type DataExportResponse struct {
+	*models.DataExport
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// exportOwner returns the authenticated user if :userId refers to them
+func exportOwner(c *gin.Context) (*models.User, bool) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return nil, false
+	}
+	authUser := authUserInterface.(*models.User)
+
+	userIdInt, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil || !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return nil, false
+	}
+	if uint(userIdInt) != authUser.ID {
+		c.Status(http.StatusForbidden)
+		return nil, false
+	}
+	return authUser, true
+}
+
+// --- Controllers ---
+
+// CreateDataExport queues an archive of everything stored about the user
+func CreateDataExport(c *gin.Context) {
+	authUser, ok := exportOwner(c)
+	if !ok {
+		return
+	}
+
+	export, err := jobs.RequestDataExport(authUser)
+	if errors.Is(err, jobs.ErrExportInProgress) {
+		c.Status(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logs.Error("Data export request failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	logs.Info("Data export requested by user: " + authUser.Username)
+	c.Header("Location", fmt.Sprintf("/v1/user/%d/export/%s", authUser.ID, export.ID))
+	c.JSON(http.StatusAccepted, DataExportResponse{DataExport: export})
+}
+
+// GetDataExport reports the export status and, once completed, a download link
+// valid for EXPORT_LINK_TTL (default 15m). Every call signs a new link.
+func GetDataExport(c *gin.Context) {
+	authUser, ok := exportOwner(c)
+	if !ok {
+		return
+	}
+
+	export, err := jobs.FindDataExport(authUser.ID, c.Param("exportId"))
+	if errors.Is(err, jobs.ErrExportNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logs.Error("Data export lookup failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	response := DataExportResponse{DataExport: export}
+	if export.Status == models.ExportCompleted {
+		response.DownloadURL, err = storage.Store.PresignGet(c.Request.Context(), export.ObjectKey, env.Duration("EXPORT_LINK_TTL", 15*time.Minute))
+		if err != nil {
+			logs.Error("Failed to sign export link: " + err.Error())
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -2,36 +2,27 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 
 	"my-project/db"
+	"my-project/imaging"
+	"my-project/jobs"
 	"my-project/logs"
 	"my-project/models"
+	"my-project/pagination"
+	"my-project/policy"
+	"my-project/storage"
 )
 
-var s3Client *s3.Client
-
-// Initialize S3 Client (This runs automatically when the package loads)
-func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(os.Getenv("AWS_REGION")))
-	if err != nil {
-		logs.Error("Unable to load SDK config for S3: " + err.Error())
-	} else {
-		s3Client = s3.NewFromConfig(cfg)
-	}
-}
-
-// CreateImage handles file upload to S3 and DB insertion
+// CreateImage handles file upload to the object store and DB insertion
 func CreateImage(c *gin.Context) {
 	// 1. Authentication Check
 	authUserInterface, exists := c.Get("user")
@@ -51,16 +42,39 @@ func CreateImage(c *gin.Context) {
 	}
 
 	// 3. File Handling (Equivalent to Multer)
+	// The body is capped so an oversized upload is cut off instead of spooled to disk
+	// (the extra megabyte leaves room for the multipart framing)
+	maxFileSize := imaging.MaxFileSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize+1<<20)
+
 	fileHeader, err := c.FormFile("file")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
 		logs.Error("Cannot find file")
 		c.Status(http.StatusBadRequest)
 		return
 	}
+	if fileHeader.Size > maxFileSize {
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	}
 
-	// 4. Validate Mime Type (Equivalent to imageFileFilter)
-	contentType := fileHeader.Header.Get("Content-Type")
-	if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/jpg" {
+	// 4. Open File Stream for Upload
+	fileContent, err := fileHeader.Open()
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	defer fileContent.Close()
+
+	// 5. Validate the contents (magic bytes + header decode), ignoring the declared Content-Type
+	detected, err := imaging.Inspect(fileContent)
+	if err != nil {
+		logs.Warn("Rejected image upload: " + err.Error())
 		c.Status(http.StatusBadRequest)
 		return
 	}
@@ -78,38 +92,26 @@ func CreateImage(c *gin.Context) {
 	// metricsClient.Timing("db.query.latency.findProduct", findDurationMs)
 
 	// Check Ownership
-	if product.OwnerUserID != authUser.ID {
+	if !policy.CanManage(authUser, product.OwnerUserID) {
 		c.Status(http.StatusForbidden)
 		return
 	}
 
-	// 5. Open File Stream for S3 Upload
-	fileContent, err := fileHeader.Open()
-	if err != nil {
-		c.Status(http.StatusBadRequest)
-		return
-	}
-	defer fileContent.Close()
-
 	// 6. Generate Unique Key
 	uniqueFileName := fmt.Sprintf("%s-%s", uuid.New().String(), fileHeader.Filename)
-	s3Key := fmt.Sprintf("%d/%d/%s", authUser.ID, productId, uniqueFileName)
+	// Keyed by the product owner (not the caller), so an admin upload lands under the owner's prefix
+	s3Key := fmt.Sprintf("%d/%d/%s", product.OwnerUserID, productId, uniqueFileName)
 
-	// --- S3: Upload (Timer) ---
+	// --- Storage: Upload (Timer) ---
 	startS3 := time.Now()
-	_, err = s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(os.Getenv("S3_BUCKET_NAME")),
-		Key:         aws.String(s3Key),
-		Body:        fileContent, // Stream directly
-		ContentType: aws.String(contentType),
-	})
+	err = storage.Store.Put(context.TODO(), s3Key, fileContent, detected.ContentType)
 	if err != nil {
-		logs.Error("S3 Upload failed: " + err.Error())
+		logs.Error("Storage upload failed: " + err.Error())
 		c.Status(http.StatusServiceUnavailable)
 		return
 	}
 	s3DurationMs := float64(time.Since(startS3).Milliseconds())
-	logs.Info("S3 Upload executed in " + strconv.FormatFloat(s3DurationMs, 'f', 2, 64) + "ms")
+	logs.Info("Storage upload executed in " + strconv.FormatFloat(s3DurationMs, 'f', 2, 64) + "ms")
 	// metricsClient.Timing("s3.upload.latency", s3DurationMs)
 
 	// 7. Insert into DB
@@ -118,6 +120,13 @@ func CreateImage(c *gin.Context) {
 		FileName:     fileHeader.Filename,
 		S3BucketPath: s3Key,
 		DateCreated:  time.Now(),
+		ContentType:  detected.ContentType,
+		Width:        detected.Width,
+		Height:       detected.Height,
+
+		// Renditions are generated in the background (jobs.ProcessImageRenditions)
+		RenditionStatus: models.RenditionsPending,
+		Renditions:      []models.ImageRendition{},
 	}
 
 	// --- DB: Insert Image (Timer) ---
@@ -131,6 +140,8 @@ func CreateImage(c *gin.Context) {
 	logs.Info("Query executed in " + strconv.FormatFloat(insertDurationMs, 'f', 2, 64) + "ms")
 	// metricsClient.Timing("db.query.latency.createImage", insertDurationMs)
 
+	jobs.Wake(jobs.ImageRenditionJob)
+
 	c.JSON(http.StatusCreated, newImage)
 }
 
@@ -157,7 +168,7 @@ func GetImage(c *gin.Context) {
 	startFind := time.Now()
 	var image models.Image
 	// Note: We check both image_id and product_id to match your logic, though image_id is PK
-	result := db.DB.Where("image_id = ? AND product_id = ?", iId, pId).First(&image)
+	result := db.DB.Preload("Renditions").Where("image_id = ? AND product_id = ?", iId, pId).First(&image)
 
 	findDurationMs := float64(time.Since(startFind).Milliseconds())
 	logs.Info("Query executed in " + strconv.FormatFloat(findDurationMs, 'f', 2, 64) + "ms")
@@ -171,31 +182,120 @@ func GetImage(c *gin.Context) {
 	c.JSON(http.StatusOK, image)
 }
 
-// GetAllImage retrieves all images (Logic copied from Node, returns ALL images in table)
+// imageSortColumns whitelists "?sort=" keys for image listings
+var imageSortColumns = map[string]pagination.Column{
+	"image_id":     {Name: "image_id", Kind: pagination.KindInt},
+	"date_created": {Name: "date_created", Kind: pagination.KindTime},
+	"file_name":    {Name: "file_name", Kind: pagination.KindString},
+}
+
+func imageSortValue(image *models.Image, column string) interface{} {
+	switch column {
+	case "date_created":
+		return image.DateCreated
+	case "file_name":
+		return image.FileName
+	default:
+		return int64(image.ImageID)
+	}
+}
+
+// listImages answers one page of query, honouring limit, cursor and sort.
+// extraParams are additional query parameters the caller already handled.
+func listImages(c *gin.Context, query *gorm.DB, metric string, extraParams ...string) {
+	params := c.Request.URL.Query()
+	if c.Request.ContentLength > 0 || !pagination.CheckQuery(params, append([]string{"limit", "cursor", "sort"}, extraParams...)...) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	limit, err := pagination.ParseLimit(params.Get("limit"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	sort, err := pagination.ParseSort(params.Get("sort"), "image_id", imageSortColumns)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	var cursor *pagination.Cursor
+	if raw := params.Get("cursor"); raw != "" {
+		if cursor, err = pagination.DecodeCursor(raw, sort); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	// --- DB: Find Page (Timer) ---
+	startFind := time.Now()
+
+	images := []models.Image{}
+	if err := pagination.Apply(query, sort, cursor, limit, "image_id").Preload("Renditions").Find(&images).Error; err != nil {
+		logs.Error("Image listing failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	findDurationMs := float64(time.Since(startFind).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(findDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency."+metric, findDurationMs)
+
+	if len(images) > limit {
+		images = images[:limit]
+		last := &images[limit-1]
+		pagination.SetNextPage(c, &pagination.Cursor{
+			Sort: sort.Key, Value: imageSortValue(last, sort.Column.Name), ID: last.ImageID,
+		})
+	}
+
+	c.JSON(http.StatusOK, images)
+}
+
+// GetAllImage lists the images of the product in the path (Public).
+// Query: limit, cursor, sort (image_id, date_created, file_name; "-" for descending).
 func GetAllImage(c *gin.Context) {
 	if c.Request.Method == "HEAD" {
 		c.Status(http.StatusMethodNotAllowed)
 		return
 	}
 
-	if len(c.Request.URL.Query()) > 0 || c.Request.ContentLength > 0 || c.GetHeader("Authorization") != "" {
+	productId, err := strconv.Atoi(c.Param("productId"))
+	if err != nil || c.GetHeader("Authorization") != "" {
 		c.Status(http.StatusBadRequest)
 		return
 	}
 
-	// --- DB: Find All (Timer) ---
+	// --- DB: Find Product (Timer) ---
 	startFind := time.Now()
-	var images []models.Image
-	db.DB.Find(&images)
-
+	var product models.Product
+	if err := db.DB.First(&product, productId).Error; err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
 	findDurationMs := float64(time.Since(startFind).Milliseconds())
 	logs.Info("Query executed in " + strconv.FormatFloat(findDurationMs, 'f', 2, 64) + "ms")
-	// metricsClient.Timing("db.query.latency.getAllImages", findDurationMs)
 
-	c.JSON(http.StatusOK, images)
+	listImages(c, db.DB.Model(&models.Image{}).Where("product_id = ?", product.ID), "getAllImages")
 }
 
-// DeleteImage handles deletion from S3 and DB
+// GetAllImageAdmin lists images across every product (admin only).
+// Same paging as GetAllImage, plus an optional product_id filter.
+func GetAllImageAdmin(c *gin.Context) {
+	query := db.DB.Model(&models.Image{})
+	if raw := c.Query("product_id"); raw != "" {
+		productId, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		query = query.Where("product_id = ?", productId)
+	}
+
+	listImages(c, query, "getAllImagesAdmin", "product_id")
+}
+
+// DeleteImage moves an image to the trash (see RestoreImage, jobs.ProcessTrash)
 func DeleteImage(c *gin.Context) {
 	authUserInterface, exists := c.Get("user")
 	if !exists {
@@ -226,7 +326,7 @@ func DeleteImage(c *gin.Context) {
 	logs.Info("Query executed in " + strconv.FormatFloat(findProdDurationMs, 'f', 2, 64) + "ms")
 	// metricsClient.Timing("db.query.latency.findProduct", findProdDurationMs)
 
-	if product.OwnerUserID != authUser.ID {
+	if !policy.CanManage(authUser, product.OwnerUserID) {
 		c.Status(http.StatusForbidden)
 		return
 	}
@@ -242,22 +342,8 @@ func DeleteImage(c *gin.Context) {
 	logs.Info("Query executed in " + strconv.FormatFloat(findImgDurationMs, 'f', 2, 64) + "ms")
 	// metricsClient.Timing("db.query.latency.findImage", findImgDurationMs)
 
-	// --- S3: Delete (Timer) ---
-	startS3Del := time.Now()
-	_, err := s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-		Bucket: aws.String(os.Getenv("S3_BUCKET_NAME")),
-		Key:    aws.String(image.S3BucketPath),
-	})
-	if err != nil {
-		logs.Error("Failed to delete image from S3: " + err.Error())
-		c.Status(http.StatusServiceUnavailable)
-		return
-	}
-	s3DelDurationMs := float64(time.Since(startS3Del).Milliseconds())
-	logs.Info("S3 Delete executed in " + strconv.FormatFloat(s3DelDurationMs, 'f', 2, 64) + "ms")
-	// metricsClient.Timing("s3.delete.latency", s3DelDurationMs)
-
-	// --- DB: Delete Image (Timer) ---
+	// --- DB: Trash Image (Timer) ---
+	// The object stays in storage so the image can be restored; jobs.ProcessTrash removes both later
 	startDelDB := time.Now()
 	if err := db.DB.Delete(&image).Error; err != nil {
 		logs.Error("Failed to delete image record: " + err.Error())
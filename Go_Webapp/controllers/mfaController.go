@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"my-project/auth"
+	"my-project/logs"
+	"my-project/models"
+)
+
+// --- Request Structs ---
+
+type ConfirmMFARequest struct {
+	Code string `json:"code"`
+}
+
+type DisableMFARequest struct {
+	Code string `json:"code"`
+}
+
+// mfaAccountOwner returns the authenticated user if :userId refers to them.
+// MFA can only be managed by the account itself, never by admins.
+func mfaAccountOwner(c *gin.Context) (*models.User, bool) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return nil, false
+	}
+	authUser := authUserInterface.(*models.User)
+
+	userIdInt, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, false
+	}
+	if uint(userIdInt) != authUser.ID {
+		c.Status(http.StatusForbidden)
+		return nil, false
+	}
+	return authUser, true
+}
+
+// --- Controllers ---
+
+// EnrollMFA starts TOTP enrollment and returns the secret and otpauth URI.
+// MFA is not enforced until ConfirmMFA succeeds.
+func EnrollMFA(c *gin.Context) {
+	authUser, ok := mfaAccountOwner(c)
+	if !ok {
+		return
+	}
+
+	if !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	secret, uri, err := auth.BeginMFAEnrollment(authUser)
+	if errors.Is(err, auth.ErrMFAAlreadyEnabled) {
+		c.Status(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logs.Error("MFA enrollment failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": uri})
+}
+
+// ConfirmMFA enables MFA with the first code from the authenticator and returns recovery codes
+func ConfirmMFA(c *gin.Context) {
+	authUser, ok := mfaAccountOwner(c)
+	if !ok {
+		return
+	}
+
+	// 1. Strict Validation
+	if !isValidRequest(c, true) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var req ConfirmMFARequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || req.Code == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// 2. Enable
+	codes, err := auth.ConfirmMFAEnrollment(authUser, req.Code)
+	if errors.Is(err, auth.ErrMFAAlreadyEnabled) {
+		c.Status(http.StatusConflict)
+		return
+	}
+	if errors.Is(err, auth.ErrMFANotEnrolled) || errors.Is(err, auth.ErrInvalidMFACode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logs.Error("MFA confirmation failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DeleteMFA turns MFA off. The body must carry a current code or an unused recovery code
+// ({"code": "..."}): a stolen access token alone must not be enough to remove the second factor.
+func DeleteMFA(c *gin.Context) {
+	authUser, ok := mfaAccountOwner(c)
+	if !ok {
+		return
+	}
+
+	// 1. Strict Validation (the body may be left out, which is answered like a missing code)
+	if len(c.Request.URL.Query()) > 0 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var req DisableMFARequest
+	if c.Request.ContentLength != 0 {
+		decoder := json.NewDecoder(c.Request.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 2. Disable
+	err := auth.DisableMFA(authUser, req.Code)
+	var locked *auth.LockedError
+	if errors.As(err, &locked) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, auth.ErrMFARequired) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, auth.ErrInvalidMFACode) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logs.Error("MFA disable failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
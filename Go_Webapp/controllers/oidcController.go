@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"my-project/auth"
+	"my-project/logs"
+	"my-project/oidc"
+)
+
+// --- Controllers ---
+
+// OIDCLogin redirects the browser to the identity provider (authorization code + PKCE)
+func OIDCLogin(c *gin.Context) {
+	if oidc.Default == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	login, err := oidc.StartLogin()
+	if err != nil {
+		logs.Error("OIDC login start failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	target, err := oidc.Default.AuthCodeURL(c.Request.Context(), login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		logs.Error("OIDC discovery failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.Redirect(http.StatusFound, target)
+}
+
+// OIDCCallback finishes the login: checks state, exchanges the code, links or provisions
+// the user and answers with the same token pair as POST /v1/auth/token.
+// Local MFA cannot be asked for here. The identity provider owns the login policy only for users
+// it provisioned; SSO logins into local accounts with MFA enabled are refused (oidc.ErrLocalMFA).
+func OIDCCallback(c *gin.Context) {
+	if oidc.Default == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	// 1. Provider Errors (user cancelled, consent denied, ...)
+	if providerError := c.Query("error"); providerError != "" {
+		logs.Info("OIDC provider returned error: " + providerError)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": providerError})
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// 2. State (CSRF protection, single use)
+	login, err := oidc.FinishLogin(state)
+	if errors.Is(err, oidc.ErrInvalidState) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logs.Error("OIDC state lookup failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	// --- IdP: Exchange Code (Timer) ---
+	startExchange := time.Now()
+
+	claims, err := oidc.Default.Exchange(c.Request.Context(), code, login.Verifier, login.Nonce)
+
+	exchangeDurationMs := float64(time.Since(startExchange).Milliseconds())
+	logs.Info("Code exchange completed in " + strconv.FormatFloat(exchangeDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("auth.oidc.exchange.latency", exchangeDurationMs)
+
+	if errors.Is(err, oidc.ErrInvalidIDToken) || errors.Is(err, oidc.ErrExchangeFailed) {
+		logs.Warn("OIDC login rejected: " + err.Error())
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		logs.Error("OIDC code exchange failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	// 3. Link or Provision
+	user, err := oidc.LinkUser(oidc.Default.Issuer(), claims)
+	if errors.Is(err, oidc.ErrEmailNotVerified) || errors.Is(err, oidc.ErrLocalMFA) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logs.Error("OIDC user linking failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	// 4. Issue our own tokens
+	pair, err := auth.IssueTokenPair(user)
+	if err != nil {
+		logs.Error("Token issue failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	logs.Info("SSO login for user: " + user.Username)
+	c.JSON(http.StatusOK, pair)
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"my-project/auth"
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/ratelimit"
+	"my-project/verify"
+)
+
+// --- Request Structs ---
+
+type ForgotPasswordRequest struct {
+	Username string `json:"username"`
+}
+
+type ResetPasswordRequest struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+var (
+	forgotLimiterOnce sync.Once
+	forgotLimiter     *ratelimit.Limiter
+)
+
+// getForgotLimiter throttles reset emails per address (FORGOT_PASSWORD_LIMIT per FORGOT_PASSWORD_WINDOW)
+func getForgotLimiter() *ratelimit.Limiter {
+	forgotLimiterOnce.Do(func() {
+		forgotLimiter = ratelimit.New(
+			env.Int("FORGOT_PASSWORD_LIMIT", 3),
+			env.Duration("FORGOT_PASSWORD_WINDOW", time.Hour),
+		)
+	})
+	return forgotLimiter
+}
+
+// resetTokenTTL is how long a reset link stays valid (RESET_TOKEN_TTL)
+func resetTokenTTL() time.Duration {
+	return env.Duration("RESET_TOKEN_TTL", 30*time.Minute)
+}
+
+// --- Controllers ---
+
+// ForgotPassword issues a single-use reset token and publishes it through SNS.
+// It always answers 202 so it cannot be used to discover which addresses have accounts.
+func ForgotPassword(c *gin.Context) {
+	// 1. Strict Validation
+	if !isValidRequest(c, true) || c.GetHeader("Authorization") != "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var req ForgotPasswordRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || !validateEmail(req.Username) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	email := strings.ToLower(req.Username)
+
+	// 2. Throttle per email address
+	if allowed, retryAfter := getForgotLimiter().Allow(email); !allowed {
+		logs.Warn("Password reset throttled for: " + email)
+		logs.Client.Increment("user.password.forgot.throttled")
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	// --- DB: Find User (Timer) ---
+	startFind := time.Now()
+	var user models.User
+	result := db.DB.Where("username = ?", email).First(&user)
+
+	findDurationMs := float64(time.Since(startFind).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(findDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.findUser", findDurationMs)
+
+	if result.Error != nil {
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	// 3. Issue Reset Token (replaces any outstanding one)
+	token, err := verify.ResetStore.Issue(context.TODO(), email, resetTokenTTL())
+	if err != nil {
+		logs.Error("Failed to store reset token: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	// 4. Publish through the registration topic with a distinct message type
+	publishUserMessage(messageTypePasswordReset, &user, token)
+
+	c.Status(http.StatusAccepted)
+}
+
+// ResetPassword redeems a reset token and sets the new password
+func ResetPassword(c *gin.Context) {
+	// 1. Strict Validation
+	if !isValidRequest(c, true) || c.GetHeader("Authorization") != "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var req ResetPasswordRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if req.Username == "" || req.Token == "" || len(req.Password) < auth.MinPasswordLength || len(req.Password) > auth.MaxPasswordLength {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	email := strings.ToLower(req.Username)
+
+	// 2. Redeem the token (single use)
+	err := verify.ResetStore.Consume(context.TODO(), email, req.Token)
+	if errors.Is(err, verify.ErrNotFound) || errors.Is(err, verify.ErrInvalidToken) || errors.Is(err, verify.ErrExpired) {
+		logs.Warn("Invalid password reset attempt for: " + email)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logs.Error("Failed to redeem reset token: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	newPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		logs.Error("Password hashing failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	// --- DB: Update Password (Timer) ---
+	startUpdate := time.Now()
+
+	var user models.User
+	if err := db.DB.Where("username = ?", email).First(&user).Error; err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	updates := map[string]interface{}{
+		"password":        newPassword,
+		"account_updated": time.Now(),
+	}
+	if err := db.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		logs.Error("Password reset update failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	updateDurationMs := float64(time.Since(startUpdate).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(updateDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.resetPassword", updateDurationMs)
+
+	// 3. Whoever knew the old password must not stay logged in, nor keep machine access
+	if err := auth.RevokeAllRefreshTokens(user.ID); err != nil {
+		logs.Error("Failed to revoke refresh tokens: " + err.Error())
+	}
+	if err := auth.RevokeAllAPIKeys(user.ID); err != nil {
+		logs.Error("Failed to revoke api keys: " + err.Error())
+	}
+
+	// 4. The reset proves control of the mailbox, so lift any lockout caused by the forgotten password
+	if err := auth.Unlock(user.Username); err != nil {
+		logs.Warn("Failed to clear login attempts: " + err.Error())
+	}
+
+	logs.Info("Password reset for user: " + email)
+	c.Status(http.StatusNoContent)
+}
@@ -1,15 +1,27 @@
 package controllers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"html"
+	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"my-project/db"
+	"my-project/env"
 	"my-project/logs"
 	"my-project/models"
+	"my-project/pagination"
+	"my-project/policy"
 )
 
 // ProductRequest matches the expected JSON input
@@ -41,6 +53,53 @@ func isValidRequest(c *gin.Context, requireBody bool) bool {
 	return true
 }
 
+// errVersionChanged aborts a conditional write whose version was bumped concurrently
+var errVersionChanged = errors.New("product version changed")
+
+// productETag is the strong entity tag of a product version
+func productETag(product *models.Product) string {
+	return `"` + strconv.Itoa(product.Version) + `"`
+}
+
+// etagListMatches reports whether a comma separated If-Match / If-None-Match value
+// contains etag or "*". weak allows W/ tags to match (If-None-Match uses weak comparison).
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces If-Match before a write. It answers 428 when the header is missing
+// and 412 when it does not match the current version. REQUIRE_IF_MATCH=false lets clients that
+// predate ETags write without the header, at the cost of silently overwriting each other.
+func checkIfMatch(c *gin.Context, product *models.Product) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if env.Bool("REQUIRE_IF_MATCH", true) {
+			c.Status(http.StatusPreconditionRequired)
+			return false
+		}
+		return true
+	}
+
+	if !etagListMatches(header, productETag(product), false) {
+		c.Header("ETag", productETag(product))
+		c.Status(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
 // CreateProduct handles creating a new product
 func CreateProduct(c *gin.Context) {
 	// Authentication check
@@ -76,14 +135,15 @@ func CreateProduct(c *gin.Context) {
 	}
 
 	newProduct := models.Product{
-		Name:         req.Name,
-		Description:  req.Description,
-		Sku:          req.Sku,
-		Manufacturer: req.Manufacturer,
-		Quantity:     *req.Quantity,
-		OwnerUserID:  authUser.ID,
-		DateAdded:    time.Now(),
+		Name:            req.Name,
+		Description:     req.Description,
+		Sku:             req.Sku,
+		Manufacturer:    req.Manufacturer,
+		Quantity:        *req.Quantity,
+		OwnerUserID:     authUser.ID,
+		DateAdded:       time.Now(),
 		DateLastUpdated: time.Now(),
+		Version:         1,
 	}
 
 	// --- DB: Insert Product (Timer) ---
@@ -99,6 +159,7 @@ func CreateProduct(c *gin.Context) {
 	logs.Info("Query executed in " + strconv.FormatFloat(insertDurationMs, 'f', 2, 64) + "ms")
 	// metricsClient.Timing("db.query.latency.createProduct", insertDurationMs)
 
+	c.Header("ETag", productETag(&newProduct))
 	c.JSON(http.StatusCreated, newProduct)
 }
 
@@ -133,36 +194,271 @@ func GetProduct(c *gin.Context) {
 		return
 	}
 
+	// Conditional GET: clients that still hold this version get an empty 304
+	etag := productETag(&product)
+	c.Header("ETag", etag)
+	if header := c.GetHeader("If-None-Match"); header != "" && etagListMatches(header, etag, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
 	c.JSON(http.StatusOK, product)
 }
 
-// GetAllProduct retrieves all products (Public route?)
-// Note: Node code selects "product", effectively selecting all fields
+// productSortColumns whitelists "?sort=" keys for GetAllProduct
+var productSortColumns = map[string]pagination.Column{
+	"id":                {Name: "id", Kind: pagination.KindInt},
+	"name":              {Name: "name", Kind: pagination.KindString},
+	"sku":               {Name: "sku", Kind: pagination.KindString},
+	"manufacturer":      {Name: "manufacturer", Kind: pagination.KindString},
+	"quantity":          {Name: "quantity", Kind: pagination.KindInt},
+	"date_added":        {Name: "date_added", Kind: pagination.KindTime},
+	"date_last_updated": {Name: "date_last_updated", Kind: pagination.KindTime},
+}
+
+// productSortValue returns the value of the sort column for a row, used to build the next cursor
+func productSortValue(product *models.Product, column string) interface{} {
+	switch column {
+	case "name":
+		return product.Name
+	case "sku":
+		return product.Sku
+	case "manufacturer":
+		return product.Manufacturer
+	case "quantity":
+		return int64(product.Quantity)
+	case "date_added":
+		return product.DateAdded
+	case "date_last_updated":
+		return product.DateLastUpdated
+	default:
+		return int64(product.ID)
+	}
+}
+
+// parseTimeParam accepts RFC 3339 timestamps or plain dates (midnight UTC)
+func parseTimeParam(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+// applyProductFilters adds the WHERE clauses for the listing filters.
+// It returns false if any filter value is malformed.
+func applyProductFilters(query *gorm.DB, params url.Values) (*gorm.DB, bool) {
+	if raw := params.Get("owner_user_id"); raw != "" {
+		ownerId, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return nil, false
+		}
+		query = query.Where("owner_user_id = ?", ownerId)
+	}
+	if raw := params.Get("manufacturer"); raw != "" {
+		query = query.Where("manufacturer = ?", raw)
+	}
+	if raw := params.Get("sku"); raw != "" {
+		query = query.Where("sku = ?", raw)
+	}
+
+	// Quantity range (inclusive)
+	for param, condition := range map[string]string{"quantity_min": "quantity >= ?", "quantity_max": "quantity <= ?"} {
+		if raw := params.Get(param); raw != "" {
+			quantity, err := strconv.Atoi(raw)
+			if err != nil || quantity < 0 || quantity > 100 {
+				return nil, false
+			}
+			query = query.Where(condition, quantity)
+		}
+	}
+
+	// Date ranges (after inclusive, before exclusive)
+	for param, condition := range map[string]string{
+		"added_after":    "date_added >= ?",
+		"added_before":   "date_added < ?",
+		"updated_after":  "date_last_updated >= ?",
+		"updated_before": "date_last_updated < ?",
+	} {
+		if raw := params.Get(param); raw != "" {
+			t, err := parseTimeParam(raw)
+			if err != nil {
+				return nil, false
+			}
+			query = query.Where(condition, t)
+		}
+	}
+
+	return query, true
+}
+
+// GetAllProduct lists products a page at a time (Public).
+// Query: limit, cursor, sort (whitelisted, "-" for descending), owner_user_id, manufacturer, sku,
+// quantity_min, quantity_max, added_after, added_before, updated_after, updated_before.
+// The body stays a JSON array; the next page is advertised in the Link and X-Next-Cursor headers.
 func GetAllProduct(c *gin.Context) {
 	if c.Request.Method == "HEAD" {
 		c.Status(http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Validation: No body, no query string
-	if !isValidRequest(c, false) {
+	// Validation: No body, only known query parameters
+	params := c.Request.URL.Query()
+	if c.Request.ContentLength > 0 || !pagination.CheckQuery(params,
+		"limit", "cursor", "sort", "owner_user_id", "manufacturer", "sku", "quantity_min", "quantity_max",
+		"added_after", "added_before", "updated_after", "updated_before") {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	limit, err := pagination.ParseLimit(params.Get("limit"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	sort, err := pagination.ParseSort(params.Get("sort"), "id", productSortColumns)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	var cursor *pagination.Cursor
+	if raw := params.Get("cursor"); raw != "" {
+		if cursor, err = pagination.DecodeCursor(raw, sort); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	query, ok := applyProductFilters(db.DB.Model(&models.Product{}), params)
+	if !ok {
 		c.Status(http.StatusBadRequest)
 		return
 	}
 
-	// --- DB: Find All (Timer) ---
+	// --- DB: Find Page (Timer) ---
 	startFind := time.Now()
 
 	var products []models.Product
-	db.DB.Find(&products) // GetRawMany equivalent
+	if err := pagination.Apply(query, sort, cursor, limit, "id").Find(&products).Error; err != nil {
+		logs.Error("Product listing failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
 
 	findDurationMs := float64(time.Since(startFind).Milliseconds())
 	logs.Info("Query executed in " + strconv.FormatFloat(findDurationMs, 'f', 2, 64) + "ms")
-	// metricsClient.Timing("db.query.latency.getAllProduct", findDurationMs)
+	logs.Client.Timing("db.query.latency.getAllProduct", findDurationMs)
+
+	// One extra row was fetched to know whether there is a next page
+	if len(products) > limit {
+		products = products[:limit]
+		last := &products[limit-1]
+		pagination.SetNextPage(c, &pagination.Cursor{
+			Sort: sort.Key, Value: productSortValue(last, sort.Column.Name), ID: last.ID,
+		})
+	}
 
 	c.JSON(http.StatusOK, products)
 }
 
+// ProductSearchResult is a product with its relevance and highlighted matches.
+// NameHighlight and Snippet are HTML: the product text is escaped and only the <mark> tags are markup.
+type ProductSearchResult struct {
+	models.Product `gorm:"embedded"`
+	Rank           float64 `gorm:"column:rank" json:"rank"`
+	NameHighlight  string  `gorm:"column:name_highlight" json:"name_highlight"`
+	Snippet        string  `gorm:"column:snippet" json:"snippet"`
+}
+
+// ts_headline marks matches with these control characters; they are stripped from the product text
+// first, so after escaping they can only stand for a match
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+var highlightReplacer = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// highlightHTML escapes a ts_headline result and turns its markers into <mark> tags
+func highlightHTML(headline string) string {
+	return highlightReplacer.Replace(html.EscapeString(headline))
+}
+
+// searchTermPattern keeps letters and digits only, so user input never reaches to_tsquery syntax
+var searchTermPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// searchQuery turns "usb lapt" into "usb:* & lapt:*": every word must match, as a prefix
+func searchQuery(q string) string {
+	terms := searchTermPattern.FindAllString(strings.ToLower(q), 10)
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// SearchProduct runs a ranked full-text search over name, manufacturer and description (Public).
+// Query: q (required), limit, offset. name_highlight and snippet are HTML-escaped text with the
+// matches wrapped in <mark>, safe to render as HTML.
+func SearchProduct(c *gin.Context) {
+	params := c.Request.URL.Query()
+	if c.Request.ContentLength > 0 || !pagination.CheckQuery(params, "q", "limit", "offset") {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	tsquery := searchQuery(params.Get("q"))
+	if tsquery == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	limit, err := pagination.ParseLimit(params.Get("limit"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	offset := 0
+	if raw := params.Get("offset"); raw != "" {
+		// Relevance order has no stable cursor; deep offsets are capped instead
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 || offset > 1000 {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	// --- DB: Search (Timer) ---
+	startSearch := time.Now()
+
+	markers := highlightStart + highlightStop
+	selectors := "StartSel=" + highlightStart + ", StopSel=" + highlightStop
+	results := []ProductSearchResult{}
+	err = db.DB.Raw(`
+		SELECT product.*,
+			ts_rank_cd(search_vector, query) AS rank,
+			ts_headline('english', translate(name, ?, ''), query, ? || ', HighlightAll=true') AS name_highlight,
+			ts_headline('english', translate(description, ?, ''), query, ? || ', MaxFragments=2, MaxWords=20, MinWords=5') AS snippet
+		FROM product, to_tsquery('english', ?) AS query
+		WHERE search_vector @@ query AND deleted_at IS NULL
+		ORDER BY rank DESC, id
+		LIMIT ? OFFSET ?`, markers, selectors, markers, selectors, tsquery, limit, offset).Scan(&results).Error
+
+	searchDurationMs := float64(time.Since(startSearch).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(searchDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.searchProduct", searchDurationMs)
+
+	if err != nil {
+		logs.Error("Product search failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	for i := range results {
+		results[i].NameHighlight = highlightHTML(results[i].NameHighlight)
+		results[i].Snippet = highlightHTML(results[i].Snippet)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
 // UpdatePutProduct handles full updates (PUT)
 func UpdatePutProduct(c *gin.Context) {
 	authUserInterface, exists := c.Get("user")
@@ -187,11 +483,15 @@ func UpdatePutProduct(c *gin.Context) {
 		return
 	}
 
-	if product.OwnerUserID != authUser.ID {
+	if !policy.CanManage(authUser, product.OwnerUserID) {
 		c.Status(http.StatusForbidden)
 		return
 	}
 
+	if !checkIfMatch(c, &product) {
+		return
+	}
+
 	var req ProductRequest
 	decoder := json.NewDecoder(c.Request.Body)
 	decoder.DisallowUnknownFields()
@@ -201,11 +501,7 @@ func UpdatePutProduct(c *gin.Context) {
 	}
 
 	// PUT requires ALL fields to be present
-	if req.Name == "" || req.Description == "" || req.Sku == "" || req.Manufacturer == "" || req.Quantity == nil {
-		c.Status(http.StatusBadRequest)
-		return
-	}
-	if *req.Quantity < 0 || *req.Quantity > 100 {
+	if !validProductRequest(&req) {
 		c.Status(http.StatusBadRequest)
 		return
 	}
@@ -213,7 +509,34 @@ func UpdatePutProduct(c *gin.Context) {
 	// --- DB: Update Product (Timer) ---
 	startUpdate := time.Now()
 
-	updates := map[string]interface{}{
+	if !updateProductVersion(c, &product, productUpdates(&req)) {
+		return
+	}
+
+	updateDurationMs := float64(time.Since(startUpdate).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(updateDurationMs, 'f', 2, 64) + "ms")
+	// metricsClient.Timing("db.query.latency.updatePutProduct", updateDurationMs)
+
+	c.Status(http.StatusNoContent)
+}
+
+// Patch formats accepted by UpdatePatchProduct. Plain application/json is treated as a merge patch.
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// validProductRequest applies the rules every stored product must satisfy (all fields present, quantity 0-100)
+func validProductRequest(req *ProductRequest) bool {
+	if req.Name == "" || req.Description == "" || req.Sku == "" || req.Manufacturer == "" || req.Quantity == nil {
+		return false
+	}
+	return *req.Quantity >= 0 && *req.Quantity <= 100
+}
+
+// productUpdates maps a validated request onto the writable columns
+func productUpdates(req *ProductRequest) map[string]interface{} {
+	return map[string]interface{}{
 		"name":              req.Name,
 		"description":       req.Description,
 		"sku":               req.Sku,
@@ -221,17 +544,58 @@ func UpdatePutProduct(c *gin.Context) {
 		"quantity":          *req.Quantity,
 		"date_last_updated": time.Now(),
 	}
+}
 
-	if err := db.DB.Model(&product).Updates(updates).Error; err != nil {
-		c.Status(http.StatusBadRequest)
-		return
-	}
+// applyProductPatch applies the request body (RFC 7396 merge patch or RFC 6902 JSON patch, chosen
+// by Content-Type) to the writable fields of product. It returns the patched document, or the
+// status to answer with: 415 for other media types, 400 for a malformed patch, 409 for a failed "test".
+func applyProductPatch(c *gin.Context, product *models.Product) ([]byte, int) {
+	current, err := json.Marshal(ProductRequest{
+		Name:         product.Name,
+		Description:  product.Description,
+		Sku:          product.Sku,
+		Manufacturer: product.Manufacturer,
+		Quantity:     &product.Quantity,
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, http.StatusBadRequest
+	}
+
+	switch c.ContentType() {
+	case mergePatchContentType, "application/json":
+		// A merge patch that is not an object would replace the whole product
+		var patch map[string]interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, http.StatusBadRequest
+		}
+		patched, err := jsonpatch.MergePatch(current, body)
+		if err != nil {
+			return nil, http.StatusBadRequest
+		}
+		return patched, 0
 
-	updateDurationMs := float64(time.Since(startUpdate).Milliseconds())
-	logs.Info("Query executed in " + strconv.FormatFloat(updateDurationMs, 'f', 2, 64) + "ms")
-	// metricsClient.Timing("db.query.latency.updatePutProduct", updateDurationMs)
+	case jsonPatchContentType:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return nil, http.StatusBadRequest
+		}
+		patched, err := patch.Apply(current)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, http.StatusConflict
+		}
+		if err != nil {
+			return nil, http.StatusBadRequest
+		}
+		return patched, 0
+	}
 
-	c.Status(http.StatusNoContent)
+	c.Header("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+	return nil, http.StatusUnsupportedMediaType
 }
 
 // UpdatePatchProduct handles partial updates (PATCH)
@@ -258,46 +622,35 @@ func UpdatePatchProduct(c *gin.Context) {
 		return
 	}
 
-	if product.OwnerUserID != authUser.ID {
+	if !policy.CanManage(authUser, product.OwnerUserID) {
 		c.Status(http.StatusForbidden)
 		return
 	}
 
-	// Use a map for PATCH to know exactly which fields were sent
-	var reqMap map[string]interface{}
-	decoder := json.NewDecoder(c.Request.Body)
-	decoder.DisallowUnknownFields()
-	if err := decoder.Decode(&reqMap); err != nil {
-		c.Status(http.StatusBadRequest)
+	if !checkIfMatch(c, &product) {
 		return
 	}
 
-	// Validate keys against allowed fields
-	allowedFields := map[string]bool{"name": true, "description": true, "sku": true, "manufacturer": true, "quantity": true}
-	for key := range reqMap {
-		if !allowedFields[key] {
-			c.Status(http.StatusBadRequest)
-			return
-		}
+	// 1. Apply the patch to the current representation
+	patched, status := applyProductPatch(c, &product)
+	if status != 0 {
+		c.Status(status)
+		return
 	}
 
-	// Validate Quantity if present
-	if val, ok := reqMap["quantity"]; ok {
-		// JSON numbers are float64 by default in map[string]interface{}
-		qFloat, ok := val.(float64)
-		if !ok || qFloat < 0 || qFloat > 100 || qFloat != float64(int(qFloat)) {
-			c.Status(http.StatusBadRequest)
-			return
-		}
+	// 2. The patched document must be a complete, valid product, exactly like a PUT body
+	var req ProductRequest
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || !validProductRequest(&req) {
+		c.Status(http.StatusBadRequest)
+		return
 	}
 
-	reqMap["date_last_updated"] = time.Now()
-
 	// --- DB: Update Product (Timer) ---
 	startUpdate := time.Now()
 
-	if err := db.DB.Model(&product).Updates(reqMap).Error; err != nil {
-		c.Status(http.StatusBadRequest)
+	if !updateProductVersion(c, &product, productUpdates(&req)) {
 		return
 	}
 
@@ -308,7 +661,30 @@ func UpdatePatchProduct(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// DeleteProduct handles deletion
+// updateProductVersion applies updates only if the product is still at the version that was
+// read (and checked against If-Match), bumping the version. A concurrent writer in between
+// makes the UPDATE match no row, which is answered with 412 like an If-Match mismatch.
+func updateProductVersion(c *gin.Context, product *models.Product, updates map[string]interface{}) bool {
+	updates["version"] = gorm.Expr("version + 1")
+
+	result := db.DB.Model(&models.Product{}).
+		Where("id = ? AND version = ?", product.ID, product.Version).
+		Updates(updates)
+	if result.Error != nil {
+		c.Status(http.StatusBadRequest)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		c.Status(http.StatusPreconditionFailed)
+		return false
+	}
+
+	product.Version++
+	c.Header("ETag", productETag(product))
+	return true
+}
+
+// DeleteProduct moves the product and its images to the trash (see RestoreProduct, jobs.ProcessTrash)
 func DeleteProduct(c *gin.Context) {
 	authUserInterface, exists := c.Get("user")
 	if !exists {
@@ -337,25 +713,46 @@ func DeleteProduct(c *gin.Context) {
 	logs.Info("Query executed in " + strconv.FormatFloat(findDurationMs, 'f', 2, 64) + "ms")
 	// metricsClient.Timing("db.query.latency.findProduct", findDurationMs)
 
-	if product.OwnerUserID != authUser.ID {
+	if !policy.CanManage(authUser, product.OwnerUserID) {
 		c.Status(http.StatusForbidden)
 		return
 	}
 
-	// --- DB: Delete Images (Timer) ---
-	startDeleteImg := time.Now()
-	
-	// Delete all images associated with this product
-	db.DB.Where("product_id = ?", id).Delete(&models.Image{})
-
-	delImgDurationMs := float64(time.Since(startDeleteImg).Milliseconds())
-	logs.Info("Query executed in " + strconv.FormatFloat(delImgDurationMs, 'f', 2, 64) + "ms")
-	// metricsClient.Timing("db.query.latency.deleteImages", delImgDurationMs)
+	if !checkIfMatch(c, &product) {
+		return
+	}
 
-	// --- DB: Delete Product (Timer) ---
+	// --- DB: Trash Product and Images (Timer) ---
 	startDeleteProd := time.Now()
 
-	db.DB.Delete(&product)
+	// The product and its images share one deleted_at, which is how RestoreProduct finds the images
+	// to bring back. The product update is conditional on the version that was checked, so a
+	// concurrent update in between rolls the whole delete back.
+	deletedAt := time.Now()
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Image{}).Where("product_id = ?", id).Update("deleted_at", deletedAt).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.Product{}).
+			Where("id = ? AND version = ?", product.ID, product.Version).
+			Updates(map[string]interface{}{"deleted_at": deletedAt, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionChanged
+		}
+		return nil
+	})
+	if errors.Is(err, errVersionChanged) {
+		c.Status(http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		logs.Error("Product delete failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
 
 	delProdDurationMs := float64(time.Since(startDeleteProd).Milliseconds())
 	logs.Info("Query executed in " + strconv.FormatFloat(delProdDurationMs, 'f', 2, 64) + "ms")
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"my-project/db"
+	"my-project/jobs"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/pagination"
+	"my-project/policy"
+)
+
+// TrashedProduct is a product in the trash, with when it goes for good
+type TrashedProduct struct {
+	models.Product
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// trashSortColumns whitelists "?sort=" keys for the trash listing
+var trashSortColumns = map[string]pagination.Column{
+	"id":         {Name: "id", Kind: pagination.KindInt},
+	"deleted_at": {Name: "deleted_at", Kind: pagination.KindTime},
+}
+
+// GetProductTrash lists the caller's deleted products, most recent first (admins see everyone's).
+// Query: limit, cursor, sort (id, deleted_at; "-" for descending).
+func GetProductTrash(c *gin.Context) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	authUser := authUserInterface.(*models.User)
+
+	params := c.Request.URL.Query()
+	if c.Request.ContentLength > 0 || !pagination.CheckQuery(params, "limit", "cursor", "sort") {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	limit, err := pagination.ParseLimit(params.Get("limit"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	sort, err := pagination.ParseSort(params.Get("sort"), "-deleted_at", trashSortColumns)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	var cursor *pagination.Cursor
+	if raw := params.Get("cursor"); raw != "" {
+		if cursor, err = pagination.DecodeCursor(raw, sort); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := db.DB.Unscoped().Model(&models.Product{}).Where("deleted_at IS NOT NULL")
+	if !policy.Allows(authUser, policy.ManageAny) {
+		query = query.Where("owner_user_id = ?", authUser.ID)
+	}
+
+	// --- DB: Find Page (Timer) ---
+	startFind := time.Now()
+
+	var products []models.Product
+	if err := pagination.Apply(query, sort, cursor, limit, "id").Find(&products).Error; err != nil {
+		logs.Error("Trash listing failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	findDurationMs := float64(time.Since(startFind).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(findDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.getProductTrash", findDurationMs)
+
+	if len(products) > limit {
+		products = products[:limit]
+		last := &products[limit-1]
+		var value interface{} = int64(last.ID)
+		if sort.Column.Name == "deleted_at" {
+			value = last.DeletedAt.Time
+		}
+		pagination.SetNextPage(c, &pagination.Cursor{Sort: sort.Key, Value: value, ID: last.ID})
+	}
+
+	retention := jobs.TrashRetention()
+	trashed := make([]TrashedProduct, 0, len(products))
+	for _, product := range products {
+		trashed = append(trashed, TrashedProduct{
+			Product:   product,
+			DeletedAt: product.DeletedAt.Time,
+			PurgeAt:   product.DeletedAt.Time.Add(retention),
+		})
+	}
+
+	c.JSON(http.StatusOK, trashed)
+}
+
+// RestoreProduct takes a product out of the trash together with the images that were deleted
+// with it. Images deleted on their own before that stay in the trash.
+func RestoreProduct(c *gin.Context) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	authUser := authUserInterface.(*models.User)
+
+	id, err := strconv.Atoi(c.Param("productId"))
+	if err != nil || !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	if err := db.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&product).Error; err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if !policy.CanManage(authUser, product.OwnerUserID) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	// --- DB: Restore Product (Timer) ---
+	startRestore := time.Now()
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Image{}).
+			Where("product_id = ? AND deleted_at = ?", product.ID, product.DeletedAt.Time).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Model(&models.Product{}).
+			Where("id = ? AND deleted_at IS NOT NULL", product.ID).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Restored (or purged) concurrently
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+
+	restoreDurationMs := float64(time.Since(startRestore).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(restoreDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.restoreProduct", restoreDurationMs)
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logs.Error("Product restore failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	product.DeletedAt = gorm.DeletedAt{}
+	product.Version++
+	c.Header("ETag", productETag(&product))
+	c.JSON(http.StatusOK, product)
+}
+
+// RestoreImage takes a single image out of the trash. Its product must not be in the trash
+// (restore the product instead).
+func RestoreImage(c *gin.Context) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	authUser := authUserInterface.(*models.User)
+
+	pId, errP := strconv.Atoi(c.Param("productId"))
+	iId, errI := strconv.Atoi(c.Param("imageId"))
+	if errP != nil || errI != nil || !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	if err := db.DB.First(&product, pId).Error; err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if !policy.CanManage(authUser, product.OwnerUserID) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	// --- DB: Restore Image (Timer) ---
+	startRestore := time.Now()
+
+	result := db.DB.Unscoped().Model(&models.Image{}).
+		Where("image_id = ? AND product_id = ? AND deleted_at IS NOT NULL", iId, pId).
+		Update("deleted_at", nil)
+
+	restoreDurationMs := float64(time.Since(startRestore).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(restoreDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.restoreImage", restoreDurationMs)
+
+	if result.Error != nil {
+		logs.Error("Image restore failed: " + result.Error.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var image models.Image
+	if err := db.DB.Preload("Renditions").First(&image, iId).Error; err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, image)
+}
@@ -3,30 +3,34 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 
+	"my-project/auth"
 	"my-project/db"
+	"my-project/env"
+	"my-project/jobs"
 	"my-project/logs"
 	"my-project/models"
+	"my-project/policy"
+	"my-project/ratelimit"
+	"my-project/verify"
 )
 
 // --- AWS Client Initialization ---
 var snsClient *sns.Client
-var ddbClient *dynamodb.Client
 
 func init() {
 	// Initialize AWS Clients lazily or on startup
@@ -35,15 +39,14 @@ func init() {
 		logs.Error("Unable to load SDK config, " + err.Error())
 	} else {
 		snsClient = sns.NewFromConfig(cfg)
-		ddbClient = dynamodb.NewFromConfig(cfg)
 	}
 }
 
 // --- Helper Functions ---
 
-func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 16)
-	return string(bytes), err
+// verifyTokenTTL is how long a verification link stays valid (VERIFY_TOKEN_TTL)
+func verifyTokenTTL() time.Duration {
+	return env.Duration("VERIFY_TOKEN_TTL", time.Hour)
 }
 
 func validateEmail(email string) bool {
@@ -58,25 +61,82 @@ func validateEmail(email string) bool {
 type CreateUserRequest struct {
 	FirstName string `json:"first_name" binding:"required"`
 	LastName  string `json:"last_name" binding:"required"`
-	Password  string `json:"password" binding:"required,min=8,max=12"`
+	Password  string `json:"password" binding:"required"` // Length checked against auth.MinPasswordLength/MaxPasswordLength
 	Username  string `json:"username" binding:"required"` // This is the email
 }
 
 type UpdateUserRequest struct {
 	FirstName string `json:"first_name" binding:"required"`
 	LastName  string `json:"last_name" binding:"required"`
-	Password  string `json:"password" binding:"required"`
+	Password  string `json:"password" binding:"required"` // Length checked against auth.MinPasswordLength/MaxPasswordLength
 }
 
-type DynamoVerifyItem struct {
-	Email string `dynamodbav:"email"`
-	Token string `dynamodbav:"token"`
-	TTL   int64  `dynamodbav:"ttl"`
+type ResendVerificationRequest struct {
+	Username string `json:"username"`
+}
+
+var (
+	resendLimiterOnce sync.Once
+	resendLimiter     *ratelimit.Limiter
+)
+
+// getResendLimiter throttles verification emails per address (RESEND_VERIFICATION_LIMIT per RESEND_VERIFICATION_WINDOW).
+// Built lazily so the values from .env are already loaded.
+func getResendLimiter() *ratelimit.Limiter {
+	resendLimiterOnce.Do(func() {
+		resendLimiter = ratelimit.New(
+			env.Int("RESEND_VERIFICATION_LIMIT", 3),
+			env.Duration("RESEND_VERIFICATION_WINDOW", time.Hour),
+		)
+	})
+	return resendLimiter
+}
+
+// SNS message types, so the email Lambda (and subscription filter policies) can tell them apart
+const (
+	messageTypeEmailVerification = "email_verification"
+	messageTypePasswordReset     = "password_reset"
+)
+
+// publishUserMessage sends a message for the email Lambda on SNS_TOPIC_ARN.
+// The token (if any) is the raw one-time token the Lambda puts into the link.
+func publishUserMessage(messageType string, user *models.User, token string) {
+	if os.Getenv("GO_ENV") == "test" {
+		return
+	}
+
+	snsMessage := map[string]string{
+		"message_type": messageType,
+		"email":        user.Username,
+		"first_name":   user.FirstName,
+	}
+	if token != "" {
+		snsMessage["token"] = token
+	}
+	msgBytes, _ := json.Marshal(snsMessage)
+	msgString := string(msgBytes)
+
+	startSNS := time.Now()
+	_, err := snsClient.Publish(context.TODO(), &sns.PublishInput{
+		TopicArn: aws.String(os.Getenv("SNS_TOPIC_ARN")),
+		Message:  aws.String(msgString),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"message_type": {DataType: aws.String("String"), StringValue: aws.String(messageType)},
+		},
+	})
+	snsDuration := time.Since(startSNS).Milliseconds()
+
+	if err != nil {
+		logs.Error("SNS Publish failed: " + err.Error())
+	} else {
+		logs.Info("Successfully published " + messageType + " message for " + user.Username + " to SNS.")
+		logs.Client.Timing("sns.publish.latency", float64(snsDuration))
+	}
 }
 
 // --- Controllers ---
 
-// VerifyEmail handles the email verification logic via DynamoDB
+// VerifyEmail redeems the token from the verification link and marks the account as verified
 func VerifyEmail(c *gin.Context) {
 	email := c.Query("email")
 	token := c.Query("token")
@@ -86,57 +146,98 @@ func VerifyEmail(c *gin.Context) {
 		return
 	}
 
-	tableName := os.Getenv("DDB_VERIFY_TABLE")
+	email = strings.ToLower(email)
 
-	// Get Item from DynamoDB
-	getItemInput := &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"email": &types.AttributeValueMemberS{Value: email},
-		},
+	// Redeem the token (it is removed from the store on success)
+	err := verify.Store.Consume(context.TODO(), email, token)
+	switch {
+	case errors.Is(err, verify.ErrExpired):
+		logs.Warn("Expired token for email: " + email)
+		c.Data(http.StatusBadRequest, "text/html; charset=utf-8", []byte("<h1>Error</h1><p>Verification link has expired. Please request a new verification email.</p>"))
+		return
+	case errors.Is(err, verify.ErrNotFound), errors.Is(err, verify.ErrInvalidToken):
+		logs.Warn("Invalid verification attempt for email: " + email)
+		c.Data(http.StatusBadRequest, "text/html; charset=utf-8", []byte("<h1>Error</h1><p>Invalid or expired verification link.</p>"))
+		return
+	case err != nil:
+		logs.Error("Failed to redeem verification token: " + err.Error())
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte("<h1>Error</h1><p>Internal server error.</p>"))
+		return
 	}
 
-	result, err := ddbClient.GetItem(context.TODO(), getItemInput)
-	if err != nil || result.Item == nil {
-		logs.Warn("Verification attempt for invalid email: " + email)
+	// Mark the account as verified
+	now := time.Now()
+	verifyResult := db.DB.Model(&models.User{}).
+		Where("username = ?", email).
+		Updates(map[string]interface{}{"verified": true, "verified_at": now})
+	if verifyResult.Error != nil {
+		logs.Error("Failed to mark user as verified: " + verifyResult.Error.Error())
+		c.Data(http.StatusServiceUnavailable, "text/html; charset=utf-8", []byte("<h1>Error</h1><p>Please try again later.</p>"))
+		return
+	}
+	if verifyResult.RowsAffected == 0 {
+		logs.Warn("Verification token for unknown user: " + email)
 		c.Data(http.StatusBadRequest, "text/html; charset=utf-8", []byte("<h1>Error</h1><p>Invalid or expired verification link.</p>"))
 		return
 	}
 
-	var record DynamoVerifyItem
-	err = attributevalue.UnmarshalMap(result.Item, &record)
-	if err != nil {
-		logs.Error("Failed to unmarshal DynamoDB item: " + err.Error())
-		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte("<h1>Error</h1><p>Internal server error.</p>"))
+	logs.Info("Successfully verified email: " + email)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<h1>Success!</h1><p>Email verified successfully! You can now log in.</p>"))
+}
+
+// ResendVerification issues a fresh verification token and republishes the registration message.
+// It always answers 202 for unknown or already verified addresses so it cannot be used to probe accounts.
+func ResendVerification(c *gin.Context) {
+	// 1. Strict Validation
+	if !isValidRequest(c, true) || c.GetHeader("Authorization") != "" {
+		c.Status(http.StatusBadRequest)
 		return
 	}
 
-	if record.Token != token {
-		logs.Warn("Invalid token for email: " + email)
-		c.Data(http.StatusBadRequest, "text/html; charset=utf-8", []byte("<h1>Error</h1><p>Invalid or expired verification link.</p>"))
+	var req ResendVerificationRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || !validateEmail(req.Username) {
+		c.Status(http.StatusBadRequest)
 		return
 	}
-
-	if record.TTL < time.Now().Unix() {
-		logs.Warn("Expired token for email: " + email)
-		c.Data(http.StatusBadRequest, "text/html; charset=utf-8", []byte("<h1>Error</h1><p>Verification link has expired. Please register again.</p>"))
+	email := strings.ToLower(req.Username)
+
+	// 2. Throttle per email address
+	if allowed, retryAfter := getResendLimiter().Allow(email); !allowed {
+		logs.Warn("Verification resend throttled for: " + email)
+		logs.Client.Increment("user.verify.resend.throttled")
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		c.Status(http.StatusTooManyRequests)
 		return
 	}
 
-	// Delete Item from DynamoDB
-	deleteItemInput := &dynamodb.DeleteItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"email": &types.AttributeValueMemberS{Value: email},
-		},
+	// --- DB: Find User (Timer) ---
+	startFind := time.Now()
+	var user models.User
+	result := db.DB.Where("username = ?", email).First(&user)
+
+	findDurationMs := float64(time.Since(startFind).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(findDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.findUser", findDurationMs)
+
+	if result.Error != nil || user.Verified {
+		c.Status(http.StatusAccepted)
+		return
 	}
-	_, err = ddbClient.DeleteItem(context.TODO(), deleteItemInput)
+
+	// 3. Regenerate the token with a fresh TTL
+	token, err := verify.Store.Issue(context.TODO(), email, verifyTokenTTL())
 	if err != nil {
-		logs.Error("Error deleting token from DynamoDB: " + err.Error())
+		logs.Error("Failed to store verification token: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
 	}
 
-	logs.Info("Successfully verified email: " + email)
-	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<h1>Success!</h1><p>Email verified successfully! You can now log in.</p>"))
+	// 4. Republish the registration message
+	publishUserMessage(messageTypeEmailVerification, &user, token)
+
+	c.Status(http.StatusAccepted)
 }
 
 // CreateUser handles user registration
@@ -165,6 +266,11 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
+	if len(req.Password) < auth.MinPasswordLength || len(req.Password) > auth.MaxPasswordLength {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
 	// --- DB: Find User (Timer) ---
 	startFind := time.Now()
 	var existingUser models.User
@@ -180,7 +286,12 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, _ := hashPassword(req.Password)
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		logs.Error("Password hashing failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
 
 	newUser := models.User{
 		FirstName: req.FirstName,
@@ -204,28 +315,15 @@ func CreateUser(c *gin.Context) {
 	logs.Info("Query executed in " + strconv.FormatFloat(insertDurationMs, 'f', 2, 64) + "ms")
 	logs.Client.Timing("db.query.latency.createUser", insertDurationMs)
 
-	// --- SNS Publish ---
-	if os.Getenv("GO_ENV") != "test" {
-		snsMessage := map[string]string{
-			"email":      newUser.Username,
-			"first_name": newUser.FirstName,
-		}
-		msgBytes, _ := json.Marshal(snsMessage)
-		msgString := string(msgBytes)
-
-		startSNS := time.Now()
-		_, err := snsClient.Publish(context.TODO(), &sns.PublishInput{
-			TopicArn: aws.String(os.Getenv("SNS_TOPIC_ARN")),
-			Message:  aws.String(msgString),
-		})
-		snsDuration := time.Since(startSNS).Milliseconds()
-
-		if err != nil {
-			logs.Error("SNS Publish failed: " + err.Error())
-		} else {
-			logs.Info("Successfully published registration message for " + newUser.Username + " to SNS.")
-			logs.Client.Timing("sns.publish.latency", float64(snsDuration))
-		}
+	// --- Verification Token ---
+	// The webapp owns token generation; the Lambda only delivers the email
+	token, err := verify.Store.Issue(context.TODO(), newUser.Username, verifyTokenTTL())
+	if err != nil {
+		// The account exists either way; the user can ask for a new link via /verifyEmail/resend
+		logs.Error("Failed to issue verification token: " + err.Error())
+	} else {
+		// --- SNS Publish ---
+		publishUserMessage(messageTypeEmailVerification, &newUser, token)
 	}
 
 	c.JSON(http.StatusCreated, newUser)
@@ -255,13 +353,25 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	if uint(userIdInt) != authUser.ID {
+	if uint(userIdInt) == authUser.ID {
+		// Return User
+		c.JSON(http.StatusOK, authUser)
+		return
+	}
+
+	// Admins and auditors may read other profiles
+	if !policy.Allows(authUser, policy.UserReadAny) {
 		c.Status(http.StatusForbidden)
 		return
 	}
 
-	// Return User
-	c.JSON(http.StatusOK, authUser)
+	var user models.User
+	if err := db.DB.First(&user, userIdInt).Error; err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
 }
 
 // UpdateUser handles user updates
@@ -298,7 +408,17 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	newPassword, _ := hashPassword(req.Password)
+	if len(req.Password) < auth.MinPasswordLength || len(req.Password) > auth.MaxPasswordLength {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	newPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		logs.Error("Password hashing failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
 
 	// --- DB: Update User (Timer) ---
 	startUpdate := time.Now()
@@ -319,5 +439,109 @@ func UpdateUser(c *gin.Context) {
 	logs.Info("Query executed in " + strconv.FormatFloat(updateDurationMs, 'f', 2, 64) + "ms")
 	logs.Client.Timing("db.query.latency.updateUser", updateDurationMs)
 
+	// A password change must log out every other session holding a refresh token,
+	// and revoke the API keys minted with the old credentials
+	if err := auth.RevokeAllRefreshTokens(authUser.ID); err != nil {
+		logs.Error("Failed to revoke refresh tokens: " + err.Error())
+	}
+	if err := auth.RevokeAllAPIKeys(authUser.ID); err != nil {
+		logs.Error("Failed to revoke api keys: " + err.Error())
+	}
+
 	c.Status(http.StatusNoContent)
 }
+
+// DeleteUser deletes the account with its products and images. The database part is done
+// before responding; storage cleanup continues in the background and is tracked by the
+// returned status resource, which stays readable without credentials.
+func DeleteUser(c *gin.Context) {
+	authUserInterface, exists := c.Get("user")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	authUser := authUserInterface.(*models.User)
+
+	userIdInt, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil || !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// Self-service, or an admin acting on someone else's request
+	if uint(userIdInt) != authUser.ID && !policy.Allows(authUser, policy.UserAdmin) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	user := authUser
+	if uint(userIdInt) != authUser.ID {
+		user = &models.User{}
+		if err := db.DB.First(user, userIdInt).Error; err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+	}
+
+	// --- DB: Delete Account (Timer) ---
+	startDelete := time.Now()
+
+	deletion, err := jobs.RequestAccountDeletion(user)
+
+	deleteDurationMs := float64(time.Since(startDelete).Milliseconds())
+	logs.Info("Query executed in " + strconv.FormatFloat(deleteDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("db.query.latency.deleteUser", deleteDurationMs)
+
+	if err != nil {
+		logs.Error("Account deletion failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.Header("Location", "/v1/user/deletions/"+deletion.ID)
+	c.JSON(http.StatusAccepted, newAccountDeletionResponse(deletion))
+}
+
+// AccountDeletionResponse is what the unauthenticated status endpoint may show: progress only,
+// nothing that identifies the deleted account
+type AccountDeletionResponse struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"`
+	ObjectsDeleted int        `json:"objects_deleted"`
+	Attempts       int        `json:"attempts"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+}
+
+func newAccountDeletionResponse(deletion *models.AccountDeletion) AccountDeletionResponse {
+	return AccountDeletionResponse{
+		ID:             deletion.ID,
+		Status:         deletion.Status,
+		ObjectsDeleted: deletion.ObjectsDeleted,
+		Attempts:       deletion.Attempts,
+		CreatedAt:      deletion.CreatedAt,
+		CompletedAt:    deletion.CompletedAt,
+	}
+}
+
+// GetAccountDeletion reports the progress of an account deletion. It needs no credentials
+// (the account is gone); the random ID is the only key, and the response names no one.
+func GetAccountDeletion(c *gin.Context) {
+	if !isValidRequest(c, false) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	deletion, err := jobs.FindAccountDeletion(c.Param("deletionId"))
+	if errors.Is(err, jobs.ErrDeletionNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logs.Error("Account deletion lookup failed: " + err.Error())
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAccountDeletionResponse(deletion))
+}
@@ -17,6 +17,21 @@ var DB *gorm.DB
 
 // InitializeDatabase connects to Postgres and performs migrations
 func InitializeDatabase() {
+	// Configure Logger
+	// Equivalent to: logging: !isTestEnv
+	var gormLogger logger.Interface
+	if os.Getenv("GO_ENV") == "test" {
+		gormLogger = logger.Discard // Silent during tests
+	} else {
+		gormLogger = logger.Default.LogMode(logger.Info) // Standard logging
+	}
+
+	InitializeDatabaseWithLogger(gormLogger)
+}
+
+// InitializeDatabaseWithLogger is InitializeDatabase with the given GORM logger, which also covers
+// the migrations. Commands that write their output to stdout pass one that stays off stdout.
+func InitializeDatabaseWithLogger(gormLogger logger.Interface) {
 	// 1. Build Connection String (DSN)
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
 		os.Getenv("DBHOST"),
@@ -26,16 +41,7 @@ func InitializeDatabase() {
 		os.Getenv("DBPORT"),
 	)
 
-	// 2. Configure Logger
-	// Equivalent to: logging: !isTestEnv
-	var gormLogger logger.Interface
-	if os.Getenv("GO_ENV") == "test" {
-		gormLogger = logger.Discard // Silent during tests
-	} else {
-		gormLogger = logger.Default.LogMode(logger.Info) // Standard logging
-	}
-
-	// 3. Connect to Database
+	// 2. Connect to Database
 	var err error
 	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
 		Logger: gormLogger,
@@ -48,7 +54,7 @@ func InitializeDatabase() {
 
 	log.Println("PostgreSQL Data Source has been initialized!")
 
-	// 4. Auto Migration (Equivalent to synchronize: true)
+	// 3. Auto Migration (Equivalent to synchronize: true)
 	// This automatically creates/updates tables based on your structs.
 	// UNCOMMENT THE BLOCK BELOW once you provide the Model files.
 
@@ -57,9 +63,24 @@ func InitializeDatabase() {
 		&models.User{},
 		&models.Product{},
 		&models.Image{},
+		&models.ImageRendition{},
+		&models.RefreshToken{},
+		&models.VerificationToken{},
+		&models.LoginAttempt{},
+		&models.APIKey{},
+		&models.MFARecoveryCode{},
+		&models.OIDCLoginState{},
+		&models.UserIdentity{},
+		&models.AccountDeletion{},
+		&models.DataExport{},
+		&models.StorageDeletion{},
 	)
 	if err != nil {
 		log.Fatalf("Migration failed: %v", err)
 	}
 
+	// 4. SQL Migrations (see db/migrations)
+	if err := runMigrations(DB); err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
 }
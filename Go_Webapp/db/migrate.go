@@ -0,0 +1,84 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schema changes AutoMigrate cannot express (generated columns, GIN indexes, ...) live in
+// db/migrations as numbered SQL files. Each runs once, in order, in its own transaction.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is an arbitrary constant for pg_advisory_lock, so that instances starting
+// at the same time do not apply the same migration twice
+const migrationLockID = 7201
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   string    `gorm:"primaryKey;column:version;type:varchar"`
+	AppliedAt time.Time `gorm:"column:applied_at;type:timestamptz;not null"`
+}
+
+// TableName ensures the table is named "schema_migrations"
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// runMigrations applies every migration not yet recorded in schema_migrations
+func runMigrations(database *gorm.DB) error {
+	if err := database.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	// The advisory lock belongs to a session, so pin one connection for lock + migrations + unlock
+	return database.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockID)
+
+		for _, name := range names {
+			version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+
+			var applied int64
+			if err := conn.Model(&SchemaMigration{}).Where("version = ?", version).Count(&applied).Error; err != nil {
+				return err
+			}
+			if applied > 0 {
+				continue
+			}
+
+			script, err := migrationFiles.ReadFile(name)
+			if err != nil {
+				return err
+			}
+
+			err = conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(string(script)).Error; err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: version, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", version, err)
+			}
+			log.Println("Applied migration " + version)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,11 @@
+-- Full-text search over products (GET /v1/product/search).
+-- Generated column: Postgres keeps it in sync on every insert/update, no triggers needed.
+-- Weights rank name matches above manufacturer, and manufacturer above description.
+ALTER TABLE product ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(manufacturer, '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'C')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_product_search_vector ON product USING GIN (search_vector);
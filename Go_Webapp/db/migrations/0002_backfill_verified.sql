@@ -0,0 +1,10 @@
+-- Email verification cutover.
+-- AutoMigrate adds users.verified with DEFAULT false, which would lock every account created before
+-- verification existed out of Basic/JWT auth (403). This runs once, on the first start of the release
+-- that introduced verification, and marks those accounts as verified. Accounts registered from then
+-- on go through VerifyEmail as usual. On a new database the table is empty and nothing changes.
+UPDATE users
+SET verified = true,
+    verified_at = now()
+WHERE verified = false
+  AND verified_at IS NULL;
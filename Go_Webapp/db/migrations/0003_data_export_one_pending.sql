@@ -0,0 +1,16 @@
+-- At most one pending data export per user.
+-- RequestDataExport checks for a pending export before inserting, but two concurrent requests can both
+-- pass that check. The partial unique index makes the second insert fail instead (mapped to 409).
+-- Duplicates queued before this ran keep the oldest row and mark the others failed, so the index can be built.
+UPDATE data_exports
+SET status = 'failed',
+    last_error = 'superseded by an earlier pending export'
+WHERE status = 'pending'
+  AND id NOT IN (
+      SELECT DISTINCT ON (user_id) id
+      FROM data_exports
+      WHERE status = 'pending'
+      ORDER BY user_id, created_at, id
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_one_pending ON data_exports (user_id) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+-- Verification tokens are stored hashed (token_hash) and the plaintext column is no longer read.
+-- Databases created before hashing still have it, holding secrets at rest; drop it.
+ALTER TABLE verification_tokens DROP COLUMN IF EXISTS token;
@@ -0,0 +1,81 @@
+package env
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-project/logs"
+)
+
+// String returns the environment variable, or def when it is unset/empty
+func String(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// Int parses the environment variable as an integer, falling back to def when unset or invalid
+func Int(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		logs.Warn("Invalid integer in " + name + ", using default")
+		return def
+	}
+	return parsed
+}
+
+// Bool parses the environment variable as a boolean ("true", "1", "false", "0", ...)
+func Bool(name string, def bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logs.Warn("Invalid boolean in " + name + ", using default")
+		return def
+	}
+	return parsed
+}
+
+// Duration parses values such as "15m" or "720h", falling back to def when unset or invalid
+func Duration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		logs.Warn("Invalid duration in " + name + ", using default")
+		return def
+	}
+	return parsed
+}
+
+// List splits a comma separated variable, trimming blanks and dropping empty entries
+func List(name string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// IsDev reports whether GO_ENV is "test" or "development". Secrets such as JWT_SECRET may only
+// fall back to a random per-process key there; elsewhere a missing secret is fatal at startup.
+func IsDev() bool {
+	switch os.Getenv("GO_ENV") {
+	case "test", "development":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+
+	"my-project/env"
+)
+
+var (
+	// ErrUnsupportedFormat is returned for rendition formats we cannot encode
+	ErrUnsupportedFormat = errors.New("imaging: unsupported output format")
+
+	// ErrTooLarge is returned before decoding images above IMAGE_MAX_PIXELS, or wider or taller
+	// than IMAGE_MAX_WIDTH x IMAGE_MAX_HEIGHT
+	ErrTooLarge = errors.New("imaging: image dimensions too large")
+)
+
+// Spec is one configured rendition, e.g. "thumb:128:jpeg"
+type Spec struct {
+	Name string
+
+	// MaxSize bounds the longest side; smaller originals are not upscaled
+	MaxSize int
+
+	// Format is "jpeg", "png" or "webp"; empty keeps the format of the original
+	Format string
+}
+
+// Rendition is an encoded rendition ready to store
+type Rendition struct {
+	Spec
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+// encoders are the output formats we can produce. The WebP encoder is pure Go and lossless
+// only (VP8L), so WebP renditions of photos are larger than JPEG ones; it suits graphics best.
+var encoders = map[string]struct {
+	contentType string
+	extension   string
+	encode      func(w io.Writer, img image.Image) error
+}{
+	"jpeg": {"image/jpeg", "jpg", func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: env.Int("IMAGE_RENDITION_JPEG_QUALITY", 85)})
+	}},
+	"png": {"image/png", "png", func(w io.Writer, img image.Image) error {
+		return png.Encode(w, img)
+	}},
+	"webp": {"image/webp", "webp", func(w io.Writer, img image.Image) error {
+		return nativewebp.Encode(w, img, nil)
+	}},
+}
+
+// Specs parses IMAGE_RENDITIONS: comma separated "name:maxSize[:format]" entries
+// (default "thumb:128,medium:512", e.g. "thumb:128,thumb_webp:128:webp"). "none" turns renditions off.
+func Specs() ([]Spec, error) {
+	raw := env.String("IMAGE_RENDITIONS", "thumb:128,medium:512")
+	if raw == "none" {
+		return nil, nil
+	}
+
+	var specs []Spec
+	seen := map[string]bool{}
+
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || seen[parts[0]] {
+			return nil, fmt.Errorf("imaging: invalid rendition %q", entry)
+		}
+		size, err := strconv.Atoi(parts[1])
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("imaging: invalid rendition size %q", entry)
+		}
+
+		spec := Spec{Name: parts[0], MaxSize: size}
+		if len(parts) == 3 {
+			spec.Format = parts[2]
+			if _, ok := encoders[spec.Format]; !ok {
+				return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, spec.Format)
+			}
+		}
+
+		seen[spec.Name] = true
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// Extension is the file extension used for a rendition's storage key
+func (r *Rendition) Extension() string {
+	return encoders[r.Format].extension
+}
+
+// Render decodes a JPEG or PNG original once and produces every spec from it.
+// The dimensions are checked from the header first, so oversized images are never decoded.
+func Render(original []byte, specs []Spec) ([]Rendition, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDimensions(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+
+	renditions := make([]Rendition, 0, len(specs))
+	for _, spec := range specs {
+		if spec.Format == "" {
+			spec.Format = format
+		}
+		encoder, ok := encoders[spec.Format]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, spec.Format)
+		}
+
+		resized := resize(src, spec.MaxSize)
+
+		var buf bytes.Buffer
+		if err := encoder.encode(&buf, resized); err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, Rendition{
+			Spec:        spec,
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+			ContentType: encoder.contentType,
+			Data:        buf.Bytes(),
+		})
+	}
+	return renditions, nil
+}
+
+// resize scales src so its longest side is at most maxSize, keeping the aspect ratio
+func resize(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+
+	if width >= height {
+		height = max(1, height*maxSize/width)
+		width = maxSize
+	} else {
+		width = max(1, width*maxSize/height)
+		height = maxSize
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
@@ -0,0 +1,81 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+
+	"my-project/env"
+)
+
+// ErrNotAnImage is returned when the bytes are not a JPEG or PNG, whatever the client declared
+var ErrNotAnImage = errors.New("imaging: not a JPEG or PNG image")
+
+// sniffedFormats maps the content types http.DetectContentType reports to image.DecodeConfig formats
+var sniffedFormats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+}
+
+// Detected is what Inspect found in an upload
+type Detected struct {
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// MaxFileSize is the largest accepted upload in bytes (IMAGE_MAX_FILE_SIZE, default 10 MiB)
+func MaxFileSize() int64 {
+	return int64(env.Int("IMAGE_MAX_FILE_SIZE", 10<<20))
+}
+
+// Inspect identifies an upload from its magic bytes and decodes its header to check the
+// dimensions, without decoding the pixels. Declared content types are not consulted.
+// r is rewound to the start before returning.
+func Inspect(r io.ReadSeeker) (*Detected, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrNotAnImage
+	}
+
+	contentType := http.DetectContentType(head[:n])
+	format, ok := sniffedFormats[contentType]
+	if !ok {
+		return nil, ErrNotAnImage
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	config, decoded, err := image.DecodeConfig(r)
+	if err != nil || decoded != format {
+		return nil, ErrNotAnImage
+	}
+
+	if err := checkDimensions(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &Detected{ContentType: contentType, Width: config.Width, Height: config.Height}, nil
+}
+
+// checkDimensions rejects images too wide or tall, and decompression bombs: small files
+// that declare more pixels than IMAGE_MAX_PIXELS and would exhaust memory when decoded
+func checkDimensions(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return ErrNotAnImage
+	}
+	if width > env.Int("IMAGE_MAX_WIDTH", 10000) || height > env.Int("IMAGE_MAX_HEIGHT", 10000) {
+		return fmt.Errorf("%w: %dx%d is above IMAGE_MAX_WIDTH x IMAGE_MAX_HEIGHT", ErrTooLarge, width, height)
+	}
+	if int64(width)*int64(height) > int64(env.Int("IMAGE_MAX_PIXELS", 50_000_000)) {
+		return ErrTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"my-project/auth"
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/storage"
+)
+
+// AccountDeletionJob is the name used with Wake
+const AccountDeletionJob = "account_deletion"
+
+// ErrDeletionNotFound is returned for unknown deletion IDs
+var ErrDeletionNotFound = errors.New("jobs: account deletion not found")
+
+// RequestAccountDeletion deletes the user and everything that references them from the
+// database in one transaction, then queues removal of their objects from storage.
+// Storage is left to the worker because it can be slow and can fail halfway.
+func RequestAccountDeletion(user *models.User) (*models.AccountDeletion, error) {
+	deletion := models.AccountDeletion{
+		ID:            uuid.New().String(),
+		UserID:        user.ID,
+		Username:      user.Username,
+		StoragePrefix: fmt.Sprintf("%d/", user.ID),
+		Status:        models.DeletionPending,
+		NextAttemptAt: time.Now(),
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the user so concurrent requests delete it only once
+		var locked models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, user.ID).Error; err != nil {
+			return err
+		}
+
+		// 2. Products and their image rows, including anything in the trash
+		ownedProducts := tx.Unscoped().Model(&models.Product{}).Select("id").Where("owner_user_id = ?", user.ID)
+		if err := tx.Unscoped().Where("product_id IN (?)", ownedProducts).Delete(&models.Image{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("owner_user_id = ?", user.ID).Delete(&models.Product{}).Error; err != nil {
+			return err
+		}
+
+		// 3. Credentials and account state
+		for _, model := range []interface{}{
+			&models.RefreshToken{}, &models.APIKey{}, &models.MFARecoveryCode{}, &models.UserIdentity{}, &models.DataExport{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("email = ?", user.Username).Delete(&models.VerificationToken{}).Error; err != nil {
+			return err
+		}
+
+		// 4. The user, and the job that finishes the cleanup
+		if err := tx.Delete(&models.User{}, user.ID).Error; err != nil {
+			return err
+		}
+		return tx.Create(&deletion).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Lockout counters are keyed by username; a new account with the same address starts clean
+	if err := auth.Unlock(user.Username); err != nil {
+		logs.Warn("Failed to clear login attempts: " + err.Error())
+	}
+
+	logs.Info("Account deleted for user: " + user.Username + " (deletion " + deletion.ID + ")")
+	logs.Client.Increment("user.account.deleted")
+	Wake(AccountDeletionJob)
+	return &deletion, nil
+}
+
+// FindAccountDeletion returns the status of a deletion
+func FindAccountDeletion(id string) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	if err := db.DB.Where("id = ?", id).First(&deletion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeletionNotFound
+		}
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// RetryAccountDeletion re-queues a deletion that ran out of attempts
+func RetryAccountDeletion(id string) error {
+	result := db.DB.Model(&models.AccountDeletion{}).
+		Where("id = ? AND status = ?", id, models.DeletionFailed).
+		Updates(map[string]interface{}{"status": models.DeletionPending, "attempts": 0, "next_attempt_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeletionNotFound
+	}
+
+	Wake(AccountDeletionJob)
+	return nil
+}
+
+// ProcessAccountDeletions works through every due deletion. Each attempt lists the prefix
+// again, so objects removed by an earlier, interrupted attempt are simply not seen twice.
+func ProcessAccountDeletions(ctx context.Context) error {
+	for {
+		processed, err := processNextDeletion(ctx)
+		if err != nil || !processed {
+			return err
+		}
+	}
+}
+
+// processNextDeletion leases one due row (skipping rows another instance holds) and runs it.
+// The attempt is counted when the row is claimed, and the result is only written if nobody
+// re-claimed the row after the lease ran out.
+func processNextDeletion(ctx context.Context) (bool, error) {
+	// 1. Claim: count the attempt, push next_attempt_at past the lease and commit
+	var deletion models.AccountDeletion
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeletionPending, time.Now()).
+			Order("next_attempt_at").
+			First(&deletion).Error
+		if err != nil {
+			return err
+		}
+
+		deletion.Attempts++
+		return tx.Model(&models.AccountDeletion{}).Where("id = ?", deletion.ID).Updates(map[string]interface{}{
+			"attempts":        deletion.Attempts,
+			"next_attempt_at": time.Now().Add(jobLease()),
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// 2. Storage, outside of any transaction
+	// --- Storage: Delete Prefix (Timer) ---
+	startDelete := time.Now()
+
+	deleted, deleteErr := deletePrefix(ctx, deletion.StoragePrefix)
+
+	deleteDurationMs := float64(time.Since(startDelete).Milliseconds())
+	logs.Info("Deleted " + strconv.Itoa(deleted) + " objects under " + deletion.StoragePrefix + " in " + strconv.FormatFloat(deleteDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("jobs.account_deletion.storage.latency", deleteDurationMs)
+
+	// 3. Record the result
+	updates := map[string]interface{}{
+		"objects_deleted": gorm.Expr("objects_deleted + ?", deleted),
+	}
+	if deleteErr == nil {
+		updates["status"] = models.DeletionCompleted
+		updates["completed_at"] = time.Now()
+		updates["last_error"] = ""
+	} else {
+		logs.Error("Account deletion " + deletion.ID + " failed: " + deleteErr.Error())
+		updates["last_error"] = deleteErr.Error()
+		updates["next_attempt_at"] = time.Now().Add(retryBackoff(deletion.Attempts))
+		if deletion.Attempts >= env.Int("ACCOUNT_DELETION_MAX_ATTEMPTS", 10) {
+			updates["status"] = models.DeletionFailed
+			logs.Client.Increment("jobs.account_deletion.failed")
+		}
+	}
+
+	result := db.DB.Model(&models.AccountDeletion{}).
+		Where("id = ? AND attempts = ?", deletion.ID, deletion.Attempts).
+		Updates(updates)
+	if result.Error != nil {
+		return true, result.Error
+	}
+	if result.RowsAffected == 0 {
+		logs.Warn("Account deletion " + deletion.ID + " was re-claimed after its lease ran out, result dropped")
+	}
+	return true, nil
+}
+
+// deletePrefix deletes every object under prefix and returns how many it removed
+// before finishing or hitting the first error
+func deletePrefix(ctx context.Context, prefix string) (int, error) {
+	objects, err := storage.Store.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, object := range objects {
+		if err := storage.Store.Delete(ctx, object.Key); err != nil {
+			return deleted, fmt.Errorf("delete %s: %w", object.Key, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// retryBackoff doubles from one minute up to an hour
+func retryBackoff(attempts int) time.Duration {
+	backoff := time.Minute
+	for i := 1; i < attempts && backoff < time.Hour; i++ {
+		backoff *= 2
+	}
+	if backoff > time.Hour {
+		backoff = time.Hour
+	}
+	return backoff
+}
@@ -0,0 +1,307 @@
+package jobs
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/storage"
+)
+
+// DataExportJob is the name used with Wake
+const DataExportJob = "data_export"
+
+var (
+	// ErrExportNotFound is returned for unknown export IDs (or another user's)
+	ErrExportNotFound = errors.New("jobs: data export not found")
+
+	// ErrExportInProgress is returned while the user already has a pending export
+	ErrExportInProgress = errors.New("jobs: data export already in progress")
+)
+
+// exportRetention is how long finished archives are kept (EXPORT_RETENTION)
+func exportRetention() time.Duration {
+	return env.Duration("EXPORT_RETENTION", 7*24*time.Hour)
+}
+
+// RequestDataExport queues an export for the user. The count is only a fast path;
+// the partial unique index on pending exports is what rules out two at once.
+func RequestDataExport(user *models.User) (*models.DataExport, error) {
+	var pending int64
+	if err := db.DB.Model(&models.DataExport{}).
+		Where("user_id = ? AND status = ?", user.ID, models.ExportPending).Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, ErrExportInProgress
+	}
+
+	export := models.DataExport{
+		ID:            uuid.New().String(),
+		UserID:        user.ID,
+		Status:        models.ExportPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := db.DB.Create(&export).Error; err != nil {
+		// A concurrent request got in first (idx_data_exports_one_pending)
+		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "23505") {
+			return nil, ErrExportInProgress
+		}
+		return nil, err
+	}
+
+	Wake(DataExportJob)
+	return &export, nil
+}
+
+// FindDataExport returns one of the user's exports
+func FindDataExport(userID uint, id string) (*models.DataExport, error) {
+	var export models.DataExport
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExportNotFound
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+// ProcessDataExports builds every due archive, then removes archives past their retention
+func ProcessDataExports(ctx context.Context) error {
+	for {
+		processed, err := processNextExport(ctx)
+		if err != nil {
+			return err
+		}
+		if !processed {
+			break
+		}
+	}
+	return expireDataExports(ctx)
+}
+
+// processNextExport leases one due export (skipping rows another instance holds) and builds it.
+// The attempt is counted when the row is claimed; the result is only written if the lease
+// is still ours, i.e. nobody re-claimed the row after it ran out.
+func processNextExport(ctx context.Context) (bool, error) {
+	// 1. Claim: count the attempt, set the lease and commit
+	var export models.DataExport
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.ExportPending, now).
+			Where("leased_until IS NULL OR leased_until <= ?", now).
+			Order("next_attempt_at").
+			First(&export).Error
+		if err != nil {
+			return err
+		}
+
+		export.Attempts++
+		return tx.Model(&models.DataExport{}).Where("id = ?", export.ID).Updates(map[string]interface{}{
+			"attempts":     export.Attempts,
+			"leased_until": now.Add(jobLease()),
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// 2. Build and upload, outside of any transaction
+	// --- Export: Build Archive (Timer) ---
+	startBuild := time.Now()
+
+	key := fmt.Sprintf("%d/exports/%s.zip", export.UserID, export.ID)
+	size, buildErr := buildExport(ctx, export.UserID, key)
+
+	buildDurationMs := float64(time.Since(startBuild).Milliseconds())
+	logs.Info("Export " + export.ID + " built in " + strconv.FormatFloat(buildDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("jobs.data_export.latency", buildDurationMs)
+
+	// 3. Record the result
+	updates := map[string]interface{}{"leased_until": nil}
+	if buildErr == nil {
+		now := time.Now()
+		updates["status"] = models.ExportCompleted
+		updates["object_key"] = key
+		updates["size_bytes"] = size
+		updates["completed_at"] = now
+		updates["expires_at"] = now.Add(exportRetention())
+		updates["last_error"] = ""
+	} else {
+		logs.Error("Data export " + export.ID + " failed: " + buildErr.Error())
+		updates["last_error"] = buildErr.Error()
+		updates["next_attempt_at"] = time.Now().Add(retryBackoff(export.Attempts))
+		if export.Attempts >= env.Int("EXPORT_MAX_ATTEMPTS", 5) {
+			updates["status"] = models.ExportFailed
+			logs.Client.Increment("jobs.data_export.failed")
+		}
+	}
+
+	result := db.DB.Model(&models.DataExport{}).
+		Where("id = ? AND attempts = ?", export.ID, export.Attempts).
+		Updates(updates)
+	if result.Error != nil {
+		return true, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// The archive under key is the same one the new owner writes, so it is left in place
+		logs.Warn("Data export " + export.ID + " was re-claimed after its lease ran out, result dropped")
+	}
+	return true, nil
+}
+
+// buildExport writes the archive to a temporary file and uploads it under key.
+// Layout: user.json, products.json, images.json, api_keys.json, identities.json,
+// manifest.json and the image files under images/<original key>.
+func buildExport(ctx context.Context, userID uint, key string) (int64, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return 0, err
+	}
+
+	// Unscoped: products and images in the trash are still the user's data until purged
+	var products []models.Product
+	if err := db.DB.Unscoped().Where("owner_user_id = ?", userID).Order("id").Find(&products).Error; err != nil {
+		return 0, err
+	}
+
+	var images []models.Image
+	ownedProducts := db.DB.Unscoped().Model(&models.Product{}).Select("id").Where("owner_user_id = ?", userID)
+	if err := db.DB.Unscoped().Preload("Renditions").Where("product_id IN (?)", ownedProducts).Order("image_id").Find(&images).Error; err != nil {
+		return 0, err
+	}
+
+	var apiKeys []models.APIKey
+	if err := db.DB.Where("user_id = ?", userID).Order("id").Find(&apiKeys).Error; err != nil {
+		return 0, err
+	}
+
+	var identities []models.UserIdentity
+	if err := db.DB.Where("user_id = ?", userID).Order("id").Find(&identities).Error; err != nil {
+		return 0, err
+	}
+
+	file, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+
+	// 1. Records
+	for name, value := range map[string]interface{}{
+		"user.json":       user,
+		"products.json":   products,
+		"images.json":     images,
+		"api_keys.json":   apiKeys,
+		"identities.json": identities,
+	} {
+		if err := writeJSON(archive, name, value); err != nil {
+			return 0, err
+		}
+	}
+
+	// 2. Image Files (a missing object is reported in the manifest, not fatal)
+	missing := []string{}
+	for _, image := range images {
+		err := copyObject(ctx, archive, image.S3BucketPath)
+		if errors.Is(err, storage.ErrNotFound) {
+			missing = append(missing, image.S3BucketPath)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	manifest := map[string]interface{}{
+		"user_id":       userID,
+		"generated_at":  time.Now().UTC(),
+		"image_count":   len(images) - len(missing),
+		"missing_files": missing,
+	}
+	if err := writeJSON(archive, "manifest.json", manifest); err != nil {
+		return 0, err
+	}
+	if err := archive.Close(); err != nil {
+		return 0, err
+	}
+
+	// 3. Upload
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := storage.Store.Put(ctx, key, file, "application/zip"); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func writeJSON(archive *zip.Writer, name string, value interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func copyObject(ctx context.Context, archive *zip.Writer, key string) error {
+	body, _, err := storage.Store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	w, err := archive.Create("images/" + strings.TrimPrefix(key, "/"))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// expireDataExports deletes archives past their retention and marks them expired
+func expireDataExports(ctx context.Context) error {
+	var expired []models.DataExport
+	if err := db.DB.Where("status = ? AND expires_at < ?", models.ExportCompleted, time.Now()).Find(&expired).Error; err != nil {
+		return err
+	}
+
+	for _, export := range expired {
+		if err := storage.Store.Delete(ctx, export.ObjectKey); err != nil {
+			logs.Warn("Failed to delete expired export " + export.ID + ": " + err.Error())
+			continue
+		}
+		if err := db.DB.Model(&models.DataExport{}).Where("id = ?", export.ID).Update("status", models.ExportExpired).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
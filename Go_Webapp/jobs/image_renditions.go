@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"my-project/db"
+	"my-project/imaging"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/storage"
+)
+
+// ImageRenditionJob is the name used with Wake
+const ImageRenditionJob = "image_renditions"
+
+// errPermanent marks rendition failures that retrying cannot fix (missing or undecodable original)
+var errPermanent = errors.New("permanent rendition failure")
+
+// RenditionKey is where a rendition is stored: next to the original, e.g. "1/2/uuid-cat.png.thumb.jpg"
+func RenditionKey(original, name, extension string) string {
+	return original + "." + name + "." + extension
+}
+
+// ProcessImageRenditions generates the configured renditions (IMAGE_RENDITIONS) for every pending
+// image. Storage errors stop the run and the image is retried once its lease ends; originals that
+// are missing or cannot be decoded are marked failed.
+func ProcessImageRenditions(ctx context.Context) error {
+	specs, err := imaging.Specs()
+	if err != nil {
+		return err
+	}
+
+	for {
+		processed, err := processNextRendition(ctx, specs)
+		if err != nil || !processed {
+			return err
+		}
+	}
+}
+
+// processNextRendition leases one pending image (skipping rows another instance holds), renders it
+// outside of any transaction and records the result, unless another worker took the image over
+// after the lease ran out
+func processNextRendition(ctx context.Context, specs []imaging.Spec) (bool, error) {
+	// 1. Claim: set the lease and commit. Postgres keeps microseconds, so truncate to compare it later.
+	var image models.Image
+	leasedUntil := time.Now().Add(jobLease()).Truncate(time.Microsecond)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("rendition_status = ?", models.RenditionsPending).
+			Where("rendition_leased_until IS NULL OR rendition_leased_until <= ?", time.Now()).
+			Order("image_id").
+			First(&image).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Image{}).Where("image_id = ?", image.ImageID).Update("rendition_leased_until", leasedUntil).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// 2. Download, render and upload
+	// --- Storage: Render (Timer) ---
+	startRender := time.Now()
+
+	renditions, renderErr := renderImage(ctx, &image, specs)
+
+	renderDurationMs := float64(time.Since(startRender).Milliseconds())
+	logs.Info("Rendered " + strconv.Itoa(len(renditions)) + " renditions of image " + strconv.Itoa(int(image.ImageID)) + " in " + strconv.FormatFloat(renderDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("jobs.image_renditions.latency", renderDurationMs)
+
+	status := models.RenditionsCompleted
+	if errors.Is(renderErr, errPermanent) {
+		logs.Warn("Renditions of image " + strconv.Itoa(int(image.ImageID)) + " failed: " + renderErr.Error())
+		logs.Client.Increment("jobs.image_renditions.failed")
+		status = models.RenditionsFailed
+		renditions = nil
+	} else if renderErr != nil {
+		// Left leased: the image is retried once the lease ends
+		return true, renderErr
+	}
+
+	// 3. Record the result. Trashed images keep their renditions for a restore.
+	discarded := false
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&models.Image{}).
+			Where("image_id = ? AND rendition_leased_until = ?", image.ImageID, leasedUntil).
+			Updates(map[string]interface{}{"rendition_status": status, "rendition_leased_until": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var err error
+			discarded, err = discardRenditions(tx, image.ImageID, renditions)
+			return err
+		}
+
+		// Replace whatever an earlier run recorded
+		if err := tx.Where("image_id = ?", image.ImageID).Delete(&models.ImageRendition{}).Error; err != nil {
+			return err
+		}
+		if len(renditions) == 0 {
+			return nil
+		}
+		return tx.Create(&renditions).Error
+	})
+	if discarded {
+		Wake(StorageDeletionJob)
+	}
+
+	return true, err
+}
+
+// discardRenditions handles a lost lease. If the image was purged meanwhile, nothing refers to the
+// uploads any more and they are queued for deletion (reported as true). Otherwise another worker
+// owns the image and writes the same keys, so they are left alone.
+func discardRenditions(tx *gorm.DB, imageID uint, renditions []models.ImageRendition) (bool, error) {
+	var remaining int64
+	if err := tx.Unscoped().Model(&models.Image{}).Where("image_id = ?", imageID).Count(&remaining).Error; err != nil {
+		return false, err
+	}
+	if remaining > 0 {
+		logs.Warn("Image " + strconv.Itoa(int(imageID)) + " was re-claimed after its rendition lease ran out, result dropped")
+		return false, nil
+	}
+
+	keys := make([]string, 0, len(renditions))
+	for _, rendition := range renditions {
+		keys = append(keys, rendition.S3BucketPath)
+	}
+	if err := EnqueueStorageDeletions(tx, keys); err != nil {
+		return false, err
+	}
+	return len(keys) > 0, nil
+}
+
+// renderImage downloads the original, renders every spec and uploads the results
+func renderImage(ctx context.Context, image *models.Image, specs []imaging.Spec) ([]models.ImageRendition, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	body, _, err := storage.Store.Get(ctx, image.S3BucketPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, errors.Join(errPermanent, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	original, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := imaging.Render(original, specs)
+	if err != nil {
+		return nil, errors.Join(errPermanent, err)
+	}
+
+	renditions := make([]models.ImageRendition, 0, len(rendered))
+	for i := range rendered {
+		rendition := &rendered[i]
+		key := RenditionKey(image.S3BucketPath, rendition.Name, rendition.Extension())
+		if err := storage.Store.Put(ctx, key, bytes.NewReader(rendition.Data), rendition.ContentType); err != nil {
+			return nil, err
+		}
+
+		renditions = append(renditions, models.ImageRendition{
+			ImageID:      image.ImageID,
+			Name:         rendition.Name,
+			Width:        rendition.Width,
+			Height:       rendition.Height,
+			ContentType:  rendition.ContentType,
+			Size:         int64(len(rendition.Data)),
+			S3BucketPath: key,
+			DateCreated:  time.Now(),
+		})
+	}
+	return renditions, nil
+}
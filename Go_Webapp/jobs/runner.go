@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"my-project/env"
+	"my-project/logs"
+)
+
+var (
+	wakeMu sync.Mutex
+	wakeup = map[string]chan struct{}{}
+)
+
+// wakeChannel returns the (buffered) channel that wakes the named job early
+func wakeChannel(name string) chan struct{} {
+	wakeMu.Lock()
+	defer wakeMu.Unlock()
+
+	ch, ok := wakeup[name]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		wakeup[name] = ch
+	}
+	return ch
+}
+
+// Wake asks the named job to run now instead of at its next tick. It never blocks,
+// and is a no-op when the workers are not running (tests call the jobs directly).
+func Wake(name string) {
+	select {
+	case wakeChannel(name) <- struct{}{}:
+	default:
+	}
+}
+
+// every runs fn on each tick or wake-up until ctx is cancelled.
+// Errors are logged; the next run retries.
+func every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	wake := wakeChannel(name)
+
+	for {
+		start := time.Now()
+		if err := fn(ctx); err != nil {
+			logs.Error("Job " + name + " failed: " + err.Error())
+			logs.Client.Increment("jobs." + name + ".error")
+		}
+		logs.Client.Timing("jobs."+name+".latency", float64(time.Since(start).Milliseconds()))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// jobLease is how long a claimed row is left alone by other workers (JOB_LEASE). Jobs claim rows
+// with FOR UPDATE SKIP LOCKED, push next_attempt_at past the lease and commit, so slow storage calls
+// never run inside a transaction. If the worker dies, the row is picked up again once the lease ends.
+func jobLease() time.Duration {
+	return env.Duration("JOB_LEASE", 10*time.Minute)
+}
+
+// Start launches every background job. Jobs coordinate through row locks
+// (FOR UPDATE SKIP LOCKED) and leases, so running them on every instance is safe.
+// JOBS_ENABLED=false turns them off, e.g. on a read-only replica.
+func Start(ctx context.Context) {
+	if !env.Bool("JOBS_ENABLED", true) {
+		log.Println("Background jobs are disabled")
+		return
+	}
+
+	go every(ctx, AccountDeletionJob, env.Duration("ACCOUNT_DELETION_INTERVAL", time.Minute), ProcessAccountDeletions)
+	go every(ctx, DataExportJob, env.Duration("EXPORT_INTERVAL", time.Minute), ProcessDataExports)
+	go every(ctx, TrashPurgeJob, env.Duration("TRASH_PURGE_INTERVAL", time.Hour), ProcessTrash)
+	go every(ctx, ImageRenditionJob, env.Duration("IMAGE_RENDITION_INTERVAL", time.Minute), ProcessImageRenditions)
+	go every(ctx, StorageDeletionJob, env.Duration("STORAGE_DELETION_INTERVAL", time.Minute), ProcessStorageDeletions)
+
+	log.Println("Background jobs have been started!")
+}
@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/storage"
+)
+
+// StorageDeletionJob is the name used with Wake
+const StorageDeletionJob = "storage_deletion"
+
+// EnqueueStorageDeletions records keys in the storage deletion outbox. Call it with the
+// transaction that deletes the rows referencing the objects, then Wake(StorageDeletionJob)
+// after it commits.
+func EnqueueStorageDeletions(tx *gorm.DB, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	deletions := make([]models.StorageDeletion, 0, len(keys))
+	for _, key := range keys {
+		deletions = append(deletions, models.StorageDeletion{ObjectKey: key, NextAttemptAt: now})
+	}
+	return tx.Create(&deletions).Error
+}
+
+// ProcessStorageDeletions deletes every due object in the outbox. A failed delete is retried
+// with backoff on a later run; entries are only removed once the object is gone.
+func ProcessStorageDeletions(ctx context.Context) error {
+	for {
+		claimed, err := processStorageDeletionBatch(ctx)
+		if err != nil || claimed == 0 {
+			return err
+		}
+	}
+}
+
+// processStorageDeletionBatch leases up to STORAGE_DELETION_BATCH due entries (skipping rows
+// another instance holds), then deletes their objects outside of any transaction
+func processStorageDeletionBatch(ctx context.Context) (int, error) {
+	// 1. Claim: push next_attempt_at past the lease and commit
+	var deletions []models.StorageDeletion
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("next_attempt_at <= ?", time.Now()).
+			Order("next_attempt_at").
+			Limit(env.Int("STORAGE_DELETION_BATCH", 100)).
+			Find(&deletions).Error
+		if err != nil || len(deletions) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(deletions))
+		for _, deletion := range deletions {
+			ids = append(ids, deletion.ID)
+		}
+		return tx.Model(&models.StorageDeletion{}).Where("id IN ?", ids).Update("next_attempt_at", time.Now().Add(jobLease())).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 2. Delete each object, then remove or reschedule its entry. Deleting an object twice is
+	// harmless, so a worker that outlives its lease needs no fencing.
+	for _, deletion := range deletions {
+		// --- Storage: Delete (Timer) ---
+		startDelete := time.Now()
+
+		deleteErr := storage.Store.Delete(ctx, deletion.ObjectKey)
+
+		deleteDurationMs := float64(time.Since(startDelete).Milliseconds())
+		logs.Client.Timing("jobs.storage_deletion.storage.latency", deleteDurationMs)
+
+		if deleteErr == nil {
+			if err := db.DB.Delete(&models.StorageDeletion{}, deletion.ID).Error; err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		logs.Warn("Storage deletion of " + deletion.ObjectKey + " failed (attempt " + strconv.Itoa(deletion.Attempts+1) + "): " + deleteErr.Error())
+		logs.Client.Increment("jobs.storage_deletion.retry")
+		if err := db.DB.Model(&models.StorageDeletion{}).Where("id = ?", deletion.ID).Updates(map[string]interface{}{
+			"attempts":        deletion.Attempts + 1,
+			"last_error":      deleteErr.Error(),
+			"next_attempt_at": time.Now().Add(retryBackoff(deletion.Attempts + 1)),
+		}).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	return len(deletions), nil
+}
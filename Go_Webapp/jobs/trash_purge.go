@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+)
+
+// TrashPurgeJob is the name used with Wake
+const TrashPurgeJob = "trash_purge"
+
+// TrashRetention is how long deleted products and images stay restorable (TRASH_RETENTION)
+func TrashRetention() time.Duration {
+	return env.Duration("TRASH_RETENTION", 30*24*time.Hour)
+}
+
+// ProcessTrash permanently removes images and then products that have been in the trash longer
+// than TRASH_RETENTION. A product goes only once none of its images are left. Image objects are
+// handed to the storage deletion outbox in the same transaction that removes their rows.
+func ProcessTrash(ctx context.Context) error {
+	cutoff := time.Now().Add(-TrashRetention())
+
+	enqueued := false
+	for {
+		purged, err := purgeImageBatch(cutoff)
+		if err != nil {
+			return err
+		}
+		if purged == 0 {
+			break
+		}
+		enqueued = true
+	}
+	if enqueued {
+		Wake(StorageDeletionJob)
+	}
+
+	// --- DB: Purge Products (Timer) ---
+	startPurge := time.Now()
+
+	result := db.DB.Unscoped().
+		Where("deleted_at < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM image WHERE image.product_id = product.id)").
+		Delete(&models.Product{})
+
+	purgeDurationMs := float64(time.Since(startPurge).Milliseconds())
+	logs.Info("Purged " + strconv.FormatInt(result.RowsAffected, 10) + " products in " + strconv.FormatFloat(purgeDurationMs, 'f', 2, 64) + "ms")
+	logs.Client.Timing("jobs.trash_purge.products.latency", purgeDurationMs)
+
+	return result.Error
+}
+
+// purgeImageBatch claims up to TRASH_PURGE_BATCH expired images (skipping rows another instance
+// holds), deletes their rows and enqueues their objects for deletion, all in one transaction
+func purgeImageBatch(cutoff time.Time) (int, error) {
+	purged := 0
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var images []models.Image
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("deleted_at < ?", cutoff).
+			Order("deleted_at").
+			Limit(env.Int("TRASH_PURGE_BATCH", 100)).
+			Find(&images).Error
+		if err != nil || len(images) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(images))
+		keys := make([]string, 0, len(images))
+		for _, image := range images {
+			ids = append(ids, image.ImageID)
+			keys = append(keys, image.S3BucketPath)
+		}
+
+		// Rendition rows go with the image (ON DELETE CASCADE); their objects are queued here
+		var renditionKeys []string
+		if err := tx.Model(&models.ImageRendition{}).Where("image_id IN ?", ids).Pluck("s3_bucket_path", &renditionKeys).Error; err != nil {
+			return err
+		}
+		keys = append(keys, renditionKeys...)
+
+		if err := EnqueueStorageDeletions(tx, keys); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("image_id IN ?", ids).Delete(&models.Image{}).Error; err != nil {
+			return err
+		}
+		purged = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if purged > 0 {
+		logs.Info("Purged " + strconv.Itoa(purged) + " images from the trash")
+	}
+	return purged, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -8,10 +9,16 @@ import (
 	"github.com/joho/godotenv"
 
 	// Import local packages
+	"my-project/auth"
 	"my-project/db"
+	"my-project/jobs"
 	"my-project/logs"
 	"my-project/middleware"
+	"my-project/oidc"
+	"my-project/policy"
 	"my-project/routes"
+	"my-project/storage"
+	"my-project/verify"
 )
 
 func main() {
@@ -39,6 +46,26 @@ func main() {
 	// 4. Connect to Database
 	db.InitializeDatabase()
 
+	// 4a. Promote the operators listed in ADMIN_USERNAMES to the admin role
+	if _, err := policy.BootstrapAdmins(db.DB); err != nil {
+		logs.Fatal("Admin bootstrap failed: " + err.Error())
+	}
+
+	// 4b. Load the JWT signing key (JWT_SECRET, required outside development)
+	auth.InitializeSigningKey()
+
+	// 4c. Initialize Object Storage (S3 or local disk, see STORAGE_BACKEND)
+	storage.InitializeStorage()
+
+	// 4d. Initialize Verification Token Store (DynamoDB, Postgres or memory, see VERIFY_STORE)
+	verify.InitializeStore()
+
+	// 4e. Configure SSO (OpenID Connect, disabled unless OIDC_ISSUER is set)
+	oidc.InitializeProvider()
+
+	// 4f. Start Background Jobs (account deletion cleanup, ...)
+	jobs.Start(context.Background())
+
 	// 5. Initialize Router
 	r := gin.New()
 
@@ -53,10 +80,19 @@ func main() {
 	v1User := r.Group("/v1/user")
 	routes.RegisterUserRoutes(v1User)
 
+	v1Auth := r.Group("/v1/auth")
+	routes.RegisterAuthRoutes(v1Auth)
+
 	v1Product := r.Group("/v1/product")
 	routes.RegisterProductRoutes(v1Product)
 	routes.RegisterImageRoutes(v1Product)
 
+	v1APIKey := r.Group("/v1/apiKey")
+	routes.RegisterAPIKeyRoutes(v1APIKey)
+
+	v1Admin := r.Group("/v1/admin")
+	routes.RegisterAdminRoutes(v1Admin)
+
 	// 8. Error Handling (404)
 	r.NoRoute(middleware.OtherRoutes())
 
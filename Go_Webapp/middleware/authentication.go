@@ -1,49 +1,132 @@
 package middleware
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
+	"my-project/auth"
 	"my-project/db"
 	"my-project/logs"
 	"my-project/models"
 )
 
-// AuthenticateUser middleware handles Basic Authentication
+// APIKeyScopesKey is the context key holding the scopes of the API key a request was authenticated with.
+// It is absent for interactive (Basic or access token) logins.
+const APIKeyScopesKey = "apiKeyScopes"
+
+// AuthenticateUser middleware accepts an API key, a Bearer access token or Basic credentials
 func AuthenticateUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 1. Get Basic Auth credentials from the standard library helper
-		username, password, hasAuth := c.Request.BasicAuth()
+		var user *models.User
+		authHeader := c.GetHeader("Authorization")
+		bearer := strings.TrimPrefix(authHeader, "Bearer ")
 
-		if !hasAuth {
-			c.Header("WWW-Authenticate", "Basic")
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
-		}
+		// 1. API Key (X-API-Key header, or a Bearer value with the key prefix)
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" || (strings.HasPrefix(authHeader, "Bearer ") && auth.IsAPIKey(bearer)) {
+			if apiKey == "" {
+				apiKey = bearer
+			}
 
-		// 2. Find User in DB
-		var user models.User
-		// Equivalent to: .where("user.username = :username", { username }).getOne()
-		if err := db.DB.Where("username = ?", username).First(&user).Error; err != nil {
-			logs.Info("Cannot find User: " + username)
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
-		}
+			var key *models.APIKey
+			var err error
+			user, key, err = auth.AuthenticateAPIKey(apiKey)
+			if err != nil {
+				logs.Info("Invalid api key")
+				unauthorized(c)
+				return
+			}
+
+			if err := auth.EnsureVerified(user); err != nil {
+				forbiddenUnverified(c)
+				return
+			}
+
+			// RequirePermission intersects these with the owner's role
+			c.Set(APIKeyScopesKey, key.ScopeList())
+		} else if strings.HasPrefix(authHeader, "Bearer ") {
+			// 2. Bearer Token (cheap: signature check + primary key lookup, no bcrypt)
+			claims, err := auth.ParseAccessToken(bearer)
+			if err != nil {
+				logs.Info("Invalid bearer token")
+				unauthorized(c)
+				return
+			}
+
+			userID, err := claims.UserID()
+			if err != nil {
+				unauthorized(c)
+				return
+			}
 
-		// 3. Compare Password
-		// bcrypt.CompareHashAndPassword returns nil on success
-		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-			logs.Info("Password does not match for user: " + username)
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
+			user = &models.User{}
+			if err := db.DB.First(user, userID).Error; err != nil {
+				logs.Info("Cannot find User for token: " + claims.Subject)
+				unauthorized(c)
+				return
+			}
+
+			if err := auth.EnsureVerified(user); err != nil {
+				forbiddenUnverified(c)
+				return
+			}
+		} else {
+			// 3. Basic Auth credentials from the standard library helper (not for accounts with MFA)
+			username, password, hasAuth := c.Request.BasicAuth()
+			if !hasAuth {
+				unauthorized(c)
+				return
+			}
+
+			var err error
+			user, err = auth.CheckBasicCredentials(username, password, c.ClientIP())
+			var locked *auth.LockedError
+			if errors.As(err, &locked) {
+				tooManyAttempts(c, locked)
+				return
+			}
+			if errors.Is(err, auth.ErrEmailNotVerified) {
+				forbiddenUnverified(c)
+				return
+			}
+			if errors.Is(err, auth.ErrMFABasicAuth) {
+				// Tell the client to switch to tokens instead of retrying
+				c.Header("WWW-Authenticate", "Bearer")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
 		}
 
 		// 4. Attach User to Context
 		// This is critical: It allows c.Get("user") to work in your controllers
-		c.Set("user", &user)
+		c.Set("user", user)
 
 		// 5. Continue to the next handler
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// unauthorized advertises both supported schemes before rejecting the request
+func unauthorized(c *gin.Context) {
+	c.Writer.Header().Add("WWW-Authenticate", "Basic")
+	c.Writer.Header().Add("WWW-Authenticate", "Bearer")
+	c.AbortWithStatus(http.StatusUnauthorized)
+}
+
+// forbiddenUnverified tells the client why valid credentials were still refused
+func forbiddenUnverified(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": auth.ErrEmailNotVerified.Error()})
+}
+
+// tooManyAttempts rejects a locked out username or IP and says when to try again
+func tooManyAttempts(c *gin.Context, locked *auth.LockedError) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	c.AbortWithStatus(http.StatusTooManyRequests)
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"my-project/logs"
+	"my-project/models"
+	"my-project/policy"
+)
+
+// RequirePermission rejects users whose role does not grant the permission.
+// Requests made with an API key also need the permission among the key's scopes.
+// Must run after AuthenticateUser. Ownership is still checked in the controllers via policy.CanManage.
+func RequirePermission(permission policy.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authUserInterface, exists := c.Get("user")
+		if !exists {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		authUser := authUserInterface.(*models.User)
+
+		if !policy.Allows(authUser, permission) {
+			logs.Warn("Permission " + string(permission) + " denied for user: " + authUser.Username)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		// API keys can only narrow what the owner's role grants
+		if scopes, isKey := c.Get(APIKeyScopesKey); isKey && !policy.ScopeAllows(scopes.([]string), permission) {
+			logs.Warn("Scope " + string(permission) + " missing on api key of user: " + authUser.Username)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RejectAPIKeys keeps account management (profile, password, keys) off API keys,
+// so a leaked key cannot be used to take over the account. Must run after AuthenticateUser.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isKey := c.Get(APIKeyScopesKey); isKey {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// Account deletion states
+const (
+	DeletionPending   = "pending"
+	DeletionCompleted = "completed"
+	DeletionFailed    = "failed"
+)
+
+// AccountDeletion tracks the asynchronous part of deleting an account: removing the
+// user's objects from storage. The database rows are already gone when this row exists.
+// The ID is random so the status can be polled without credentials (the account no longer exists).
+type AccountDeletion struct {
+	ID string `gorm:"primaryKey;column:id;type:varchar;<-:create" json:"id"`
+
+	UserID uint `gorm:"column:user_id;not null;index;<-:create" json:"-"`
+
+	Username string `gorm:"column:username;type:varchar;not null;<-:create" json:"-"`
+
+	// Every object under this prefix is deleted ("{userId}/", see CreateImage)
+	StoragePrefix string `gorm:"column:storage_prefix;type:varchar;not null;<-:create" json:"-"`
+
+	Status string `gorm:"column:status;type:varchar;not null;index" json:"status"`
+
+	ObjectsDeleted int `gorm:"column:objects_deleted;not null;default:0" json:"objects_deleted"`
+
+	Attempts int `gorm:"column:attempts;not null;default:0" json:"attempts"`
+
+	LastError string `gorm:"column:last_error;type:varchar" json:"-"`
+
+	// When the worker may pick the row up again after a failure
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at;type:timestamptz;not null" json:"-"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:CURRENT_TIMESTAMP;<-:create" json:"created_at"`
+
+	CompletedAt *time.Time `gorm:"column:completed_at;type:timestamptz" json:"completed_at"`
+}
+
+// TableName ensures the table is named "account_deletions"
+func (AccountDeletion) TableName() string {
+	return "account_deletions"
+}
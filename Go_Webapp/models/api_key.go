@@ -0,0 +1,42 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey is a long-lived credential for machine clients. Only the SHA-256 hash of the
+// key is stored; Prefix keeps enough of the raw key for users to tell keys apart.
+type APIKey struct {
+	ID uint `gorm:"primaryKey;autoIncrement;column:id;<-:create" json:"id"`
+
+	UserID uint `gorm:"column:user_id;not null;index;<-:create" json:"-"`
+
+	Name string `gorm:"column:name;type:varchar;not null;<-:create" json:"name"`
+
+	Prefix string `gorm:"column:prefix;type:varchar;not null;<-:create" json:"prefix"`
+
+	KeyHash string `gorm:"column:key_hash;type:varchar;not null;uniqueIndex;<-:create" json:"-"`
+
+	// Comma separated policy permissions, e.g. "product:read,product:write"
+	Scopes string `gorm:"column:scopes;type:varchar;not null;<-:create" json:"-"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:CURRENT_TIMESTAMP;<-:create" json:"created_at"`
+
+	LastUsedAt *time.Time `gorm:"column:last_used_at;type:timestamptz" json:"last_used_at"`
+
+	RevokedAt *time.Time `gorm:"column:revoked_at;type:timestamptz" json:"revoked_at"`
+}
+
+// ScopeList splits the stored scopes
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// TableName ensures the table is named "api_keys"
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// Data export states
+const (
+	ExportPending   = "pending"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+	ExportExpired   = "expired"
+)
+
+// DataExport is a user's request for a copy of everything stored about them.
+// The archive is built in the background and kept in object storage until ExpiresAt.
+type DataExport struct {
+	ID string `gorm:"primaryKey;column:id;type:varchar;<-:create" json:"id"`
+
+	UserID uint `gorm:"column:user_id;not null;index;<-:create" json:"user_id"`
+
+	Status string `gorm:"column:status;type:varchar;not null;index" json:"status"`
+
+	// "{userId}/exports/{id}.zip", inside the user's prefix so account deletion removes it too
+	ObjectKey string `gorm:"column:object_key;type:varchar" json:"-"`
+
+	SizeBytes int64 `gorm:"column:size_bytes;not null;default:0" json:"size_bytes"`
+
+	Attempts int `gorm:"column:attempts;not null;default:0" json:"-"`
+
+	LastError string `gorm:"column:last_error;type:varchar" json:"-"`
+
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at;type:timestamptz;not null" json:"-"`
+
+	// Set while a worker builds the archive; other workers skip the row until then (see JOB_LEASE)
+	LeasedUntil *time.Time `gorm:"column:leased_until;type:timestamptz" json:"-"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:CURRENT_TIMESTAMP;<-:create" json:"created_at"`
+
+	CompletedAt *time.Time `gorm:"column:completed_at;type:timestamptz" json:"completed_at"`
+
+	// The archive is deleted after this
+	ExpiresAt *time.Time `gorm:"column:expires_at;type:timestamptz" json:"expires_at"`
+}
+
+// TableName ensures the table is named "data_exports"
+func (DataExport) TableName() string {
+	return "data_exports"
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Image struct {
@@ -21,6 +23,29 @@ type Image struct {
 
 	// Equivalent to: s3_bucket_path: { type: "varchar", update: false }
 	S3BucketPath string `gorm:"column:s3_bucket_path;type:varchar;not null;<-:create" json:"s3_bucket_path"`
+
+	// Detected from the file contents on upload (imaging.Inspect), not taken from the client.
+	// Empty/zero for rows uploaded before detection existed.
+	ContentType string `gorm:"column:content_type;type:varchar;not null;default:'';<-:create" json:"content_type"`
+
+	Width int `gorm:"column:width;not null;default:0;<-:create" json:"width"`
+
+	Height int `gorm:"column:height;not null;default:0;<-:create" json:"height"`
+
+	// Resized copies, generated in the background after upload (jobs.ProcessImageRenditions).
+	// Rows that predate renditions start out pending, so they are backfilled too.
+	RenditionStatus string `gorm:"column:rendition_status;type:varchar;not null;default:pending;index" json:"rendition_status"`
+
+	// Set while a worker renders the image; other workers skip it until then (see JOB_LEASE)
+	RenditionLeasedUntil *time.Time `gorm:"column:rendition_leased_until;type:timestamptz" json:"-"`
+
+	Renditions []ImageRendition `gorm:"foreignKey:ImageID;references:ImageID;constraint:OnDelete:CASCADE" json:"renditions"`
+
+	// Set by the reconciliation command (cmd/reconcile) while the object is missing from storage
+	MissingAt *time.Time `gorm:"column:missing_at;type:timestamptz" json:"missing_at,omitempty"`
+
+	// Set when the image (or its product) is moved to the trash; the object stays in storage until purged
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
 }
 
 // TableName ensures the table is named "image"
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Image.RenditionStatus values
+const (
+	RenditionsPending   = "pending"
+	RenditionsCompleted = "completed"
+	RenditionsFailed    = "failed"
+)
+
+// ImageRendition is a resized copy of an image (see IMAGE_RENDITIONS), stored next to the
+// original under the same "{userId}/{productId}/" prefix
+type ImageRendition struct {
+	ID uint `gorm:"primaryKey;autoIncrement;column:id;<-:create" json:"-"`
+
+	ImageID uint `gorm:"column:image_id;not null;uniqueIndex:idx_image_rendition_name;<-:create" json:"-"`
+
+	// Configured name, e.g. "thumb"
+	Name string `gorm:"column:name;type:varchar;not null;uniqueIndex:idx_image_rendition_name;<-:create" json:"name"`
+
+	Width int `gorm:"column:width;not null" json:"width"`
+
+	Height int `gorm:"column:height;not null" json:"height"`
+
+	ContentType string `gorm:"column:content_type;type:varchar;not null" json:"content_type"`
+
+	Size int64 `gorm:"column:size;not null" json:"size"`
+
+	S3BucketPath string `gorm:"column:s3_bucket_path;type:varchar;not null" json:"s3_bucket_path"`
+
+	DateCreated time.Time `gorm:"column:date_created;type:timestamptz;default:CURRENT_TIMESTAMP" json:"date_created"`
+}
+
+// TableName ensures the table is named "image_renditions"
+func (ImageRendition) TableName() string {
+	return "image_renditions"
+}
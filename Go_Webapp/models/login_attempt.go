@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt counts consecutive failed logins per key ("user:<username>" or "ip:<address>").
+// Stored in Postgres so every instance behind the load balancer sees the same counters.
+type LoginAttempt struct {
+	Key string `gorm:"primaryKey;column:key;type:varchar" json:"key"`
+
+	Failures int `gorm:"column:failures;not null;default:0" json:"failures"`
+
+	LastFailureAt time.Time `gorm:"column:last_failure_at;type:timestamptz;not null" json:"last_failure_at"`
+
+	// Nullable: only set once the key crossed its threshold
+	LockedUntil *time.Time `gorm:"column:locked_until;type:timestamptz" json:"locked_until"`
+}
+
+// TableName ensures the table is named "login_attempts"
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// MFARecoveryCode is a single-use fallback for a lost authenticator. Only the hash is stored.
+type MFARecoveryCode struct {
+	ID uint `gorm:"primaryKey;autoIncrement;column:id;<-:create" json:"id"`
+
+	UserID uint `gorm:"column:user_id;not null;index;<-:create" json:"user_id"`
+
+	CodeHash string `gorm:"column:code_hash;type:varchar;not null;<-:create" json:"-"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:CURRENT_TIMESTAMP;<-:create" json:"created_at"`
+
+	// Set when the code is redeemed
+	UsedAt *time.Time `gorm:"column:used_at;type:timestamptz" json:"used_at"`
+}
+
+// TableName ensures the table is named "mfa_recovery_codes"
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
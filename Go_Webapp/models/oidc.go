@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// OIDCLoginState holds what the callback needs to finish an SSO login started by /v1/auth/oidc/login.
+// Keyed by the hash of the state parameter; rows are deleted when the callback consumes them.
+type OIDCLoginState struct {
+	StateHash string `gorm:"primaryKey;column:state_hash;type:varchar" json:"-"`
+
+	// PKCE code_verifier, sent to the token endpoint
+	CodeVerifier string `gorm:"column:code_verifier;type:varchar;not null" json:"-"`
+
+	Nonce string `gorm:"column:nonce;type:varchar;not null" json:"-"`
+
+	ExpiresAt time.Time `gorm:"column:expires_at;type:timestamptz;not null;index" json:"expires_at"`
+}
+
+// TableName ensures the table is named "oidc_login_states"
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+// UserIdentity links a local user to an account at an external identity provider.
+// (issuer, subject) is the stable identifier; emails can change at the provider.
+type UserIdentity struct {
+	ID uint `gorm:"primaryKey;autoIncrement;column:id;<-:create" json:"id"`
+
+	UserID uint `gorm:"column:user_id;not null;index;<-:create" json:"user_id"`
+
+	Issuer string `gorm:"column:issuer;type:varchar;not null;uniqueIndex:idx_identity_issuer_subject;<-:create" json:"issuer"`
+
+	Subject string `gorm:"column:subject;type:varchar;not null;uniqueIndex:idx_identity_issuer_subject;<-:create" json:"subject"`
+
+	// Provisioned is true when the SSO login created the account. Only then does the identity
+	// provider own the login policy; identities linked to an existing local account still
+	// answer to its local MFA.
+	Provisioned bool `gorm:"column:provisioned;not null;default:false;<-:create" json:"provisioned"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:CURRENT_TIMESTAMP;<-:create" json:"created_at"`
+}
+
+// TableName ensures the table is named "user_identities"
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
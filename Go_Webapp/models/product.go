@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Product struct {
@@ -33,6 +35,13 @@ type Product struct {
 
 	// Equivalent to: owner_user_id: { type: "int", update: false }
 	OwnerUserID uint `gorm:"column:owner_user_id;not null;<-:create" json:"owner_user_id"`
+
+	// Incremented on every update; exposed as the ETag for optimistic concurrency (If-Match)
+	Version int `gorm:"column:version;not null;default:1" json:"version"`
+
+	// Set when the product is moved to the trash; GORM hides trashed rows from normal queries.
+	// Purged for good after TRASH_RETENTION.
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
 }
 
 // TableName ensures the table is named "product"
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken stores the SHA-256 hash of an issued refresh token (never the raw value).
+// Every rotation creates a new row in the same family, so reuse of an old token can revoke the whole chain.
+type RefreshToken struct {
+	ID uint `gorm:"primaryKey;autoIncrement;column:id;<-:create" json:"id"`
+
+	UserID uint `gorm:"column:user_id;not null;index;<-:create" json:"user_id"`
+
+	// All tokens produced from one login share a family ID
+	FamilyID string `gorm:"column:family_id;type:varchar;not null;index;<-:create" json:"family_id"`
+
+	TokenHash string `gorm:"column:token_hash;type:varchar;not null;uniqueIndex;<-:create" json:"-"`
+
+	ExpiresAt time.Time `gorm:"column:expires_at;type:timestamptz;not null;<-:create" json:"expires_at"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:CURRENT_TIMESTAMP;<-:create" json:"created_at"`
+
+	// Set when the token is rotated or explicitly revoked
+	RevokedAt *time.Time `gorm:"column:revoked_at;type:timestamptz" json:"revoked_at"`
+}
+
+// TableName ensures the table is named "refresh_tokens"
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
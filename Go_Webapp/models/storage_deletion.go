@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// StorageDeletion is an outbox entry: an object to remove from storage. It is written in the same
+// transaction that deletes the rows referencing the object, so the object is never forgotten,
+// and removed once the object is gone. Failed attempts are retried with backoff until they succeed.
+type StorageDeletion struct {
+	ID uint `gorm:"primaryKey;autoIncrement;column:id;<-:create" json:"id"`
+
+	ObjectKey string `gorm:"column:object_key;type:varchar;not null;<-:create" json:"object_key"`
+
+	Attempts int `gorm:"column:attempts;not null;default:0" json:"attempts"`
+
+	LastError string `gorm:"column:last_error;type:varchar" json:"last_error"`
+
+	// When the worker may pick the row up (again)
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at;type:timestamptz;not null;index" json:"next_attempt_at"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:CURRENT_TIMESTAMP;<-:create" json:"created_at"`
+}
+
+// TableName ensures the table is named "storage_deletions"
+func (StorageDeletion) TableName() string {
+	return "storage_deletions"
+}
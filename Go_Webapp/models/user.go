@@ -27,6 +27,23 @@ type User struct {
 	// Equivalent to: account_updated: { default: NOW(), update: false, nullable: true }
 	// Note: You specified update: false in Node, so I kept <-:create here.
 	AccountUpdated time.Time `gorm:"column:account_updated;type:timestamptz;default:CURRENT_TIMESTAMP;<-:create" json:"account_updated"`
+
+	// One of policy.RoleUser, policy.RoleAdmin, policy.RoleAuditor
+	Role string `gorm:"column:role;type:varchar;not null;default:'user'" json:"role"`
+
+	// Set by VerifyEmail once the user clicks the link delivered through SNS
+	Verified bool `gorm:"column:verified;not null;default:false" json:"verified"`
+
+	// Nullable: stays NULL until the address has been verified
+	VerifiedAt *time.Time `gorm:"column:verified_at;type:timestamptz" json:"verified_at"`
+
+	// TOTP second factor. MFASecret is set at enrollment; MFAEnabled only once a code was confirmed.
+	MFAEnabled bool `gorm:"column:mfa_enabled;not null;default:false" json:"mfa_enabled"`
+
+	MFASecret string `gorm:"column:mfa_secret;type:varchar" json:"-"`
+
+	// Last accepted TOTP time step, so a code cannot be replayed within its window
+	MFALastStep int64 `gorm:"column:mfa_last_step;not null;default:0" json:"-"`
 }
 
 // TableName ensures the table is named "users"
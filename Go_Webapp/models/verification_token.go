@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// VerificationToken backs the Postgres implementation of verify.VerificationStore.
+// One outstanding token per (purpose, email); issuing again overwrites it.
+type VerificationToken struct {
+	Purpose string `gorm:"primaryKey;column:purpose;type:varchar" json:"purpose"`
+
+	Email string `gorm:"primaryKey;column:email;type:varchar" json:"email"`
+
+	// Keyed hash of the token; the raw value only exists in the emailed link
+	TokenHash string `gorm:"column:token_hash;type:varchar;not null" json:"-"`
+
+	ExpiresAt time.Time `gorm:"column:expires_at;type:timestamptz;not null" json:"expires_at"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// TableName ensures the table is named "verification_tokens"
+func (VerificationToken) TableName() string {
+	return "verification_tokens"
+}
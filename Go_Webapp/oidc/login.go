@@ -0,0 +1,209 @@
+package oidc
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"my-project/auth"
+	"my-project/db"
+	"my-project/logs"
+	"my-project/models"
+)
+
+// stateTTL is how long the user has to finish logging in at the provider
+const stateTTL = 10 * time.Minute
+
+var (
+	// ErrInvalidState covers unknown, expired and already used state parameters
+	ErrInvalidState = errors.New("oidc: invalid state")
+
+	// ErrEmailNotVerified means the provider did not vouch for the email address
+	ErrEmailNotVerified = errors.New("oidc: email not verified by the identity provider")
+
+	// ErrLocalMFA means the local account has MFA enabled and was not created by SSO, so an
+	// SSO login would bypass the second factor. The user logs in with password and code instead.
+	ErrLocalMFA = errors.New("oidc: account uses local MFA, log in with password and code")
+)
+
+// Login is an in-flight authorization request
+type Login struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// StartLogin generates state, nonce and PKCE verifier and remembers them for the callback
+func StartLogin() (*Login, error) {
+	var login Login
+	for _, value := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		token, err := auth.NewRandomToken()
+		if err != nil {
+			return nil, err
+		}
+		*value = token
+	}
+
+	// Opportunistic cleanup of abandoned logins
+	db.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+
+	record := models.OIDCLoginState{
+		StateHash:    auth.HashToken(login.State),
+		CodeVerifier: login.Verifier,
+		Nonce:        login.Nonce,
+		ExpiresAt:    time.Now().Add(stateTTL),
+	}
+	if err := db.DB.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// FinishLogin consumes the state (single use) and returns the stored login
+func FinishLogin(state string) (*Login, error) {
+	var records []models.OIDCLoginState
+	err := db.DB.Clauses(clause.Returning{}).
+		Where("state_hash = ?", auth.HashToken(state)).
+		Delete(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || records[0].ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidState
+	}
+
+	return &Login{State: state, Nonce: records[0].Nonce, Verifier: records[0].CodeVerifier}, nil
+}
+
+// LinkUser resolves the ID token to a local user:
+//  1. an existing identity link (issuer, sub),
+//  2. otherwise an existing user with the same verified email, which gets linked; an unverified
+//     one is taken over: whoever registered it never proved the address, so its password, refresh
+//     tokens and API keys are revoked,
+//  3. otherwise a new user is provisioned (verified, with an unusable random password).
+//
+// Local MFA is only skipped for provisioned accounts. Accounts with MFA enabled are never linked,
+// and a linked (not provisioned) identity is refused once its user has enabled MFA (ErrLocalMFA).
+func LinkUser(issuer string, claims *Claims) (*models.User, error) {
+	var user models.User
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// 1. Known Identity
+		var identity models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", issuer, claims.Subject).First(&identity).Error
+		if err == nil {
+			if err := tx.First(&user, identity.UserID).Error; err != nil {
+				return err
+			}
+			if user.MFAEnabled && !identity.Provisioned {
+				return ErrLocalMFA
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Linking or provisioning by email is only safe if the provider verified it
+		if !claims.EmailVerified || claims.Email == "" {
+			return ErrEmailNotVerified
+		}
+		email := strings.ToLower(claims.Email)
+
+		// 2. Existing Local Account
+		provisioned := false
+		err = tx.Where("username = ?", email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 3. Provision
+			if user, err = provisionUser(tx, email, claims); err != nil {
+				return err
+			}
+			provisioned = true
+		} else if err != nil {
+			return err
+		} else if user.MFAEnabled {
+			// Controlling the address at the provider must not replace the second factor
+			return ErrLocalMFA
+		} else if !user.Verified {
+			// The provider proved ownership of the address, the local registration did not
+			if err := claimUnverifiedUser(tx, &user); err != nil {
+				return err
+			}
+		}
+
+		logs.Info("Linking " + issuer + " subject " + claims.Subject + " to user: " + email)
+		return tx.Create(&models.UserIdentity{UserID: user.ID, Issuer: issuer, Subject: claims.Subject, Provisioned: provisioned}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// claimUnverifiedUser hands an unverified local account to the SSO login. Anyone could have
+// registered the address, so everything they could authenticate with is replaced or revoked.
+func claimUnverifiedUser(tx *gorm.DB, user *models.User) error {
+	hashed, err := unusablePassword()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(user).Updates(map[string]interface{}{"password": hashed, "verified": true, "verified_at": now}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	logs.Warn("Took over unverified user from SSO: " + user.Username)
+	return nil
+}
+
+// unusablePassword hashes a random password nobody knows. SSO users never log in with a password;
+// this keeps the column NOT NULL and makes Basic Auth impossible until they set one through the reset flow.
+func unusablePassword() (string, error) {
+	random, err := auth.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+	return auth.HashPassword(random[:auth.MaxPasswordLength/2])
+}
+
+func provisionUser(tx *gorm.DB, email string, claims *Claims) (models.User, error) {
+	hashed, err := unusablePassword()
+	if err != nil {
+		return models.User{}, err
+	}
+
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(email, "@")
+	}
+	if lastName == "" {
+		lastName = "-"
+	}
+
+	now := time.Now()
+	user := models.User{
+		Username:   email,
+		Password:   hashed,
+		FirstName:  firstName,
+		LastName:   lastName,
+		Verified:   true,
+		VerifiedAt: &now,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return models.User{}, err
+	}
+
+	logs.Info("Provisioned user from SSO: " + email)
+	logs.Client.Increment("auth.oidc.provisioned")
+	return user, nil
+}
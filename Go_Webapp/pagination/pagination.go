@@ -0,0 +1,172 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"my-project/env"
+)
+
+// ErrInvalidCursor is returned for cursors that do not decode or belong to another sort order
+var ErrInvalidCursor = errors.New("pagination: invalid cursor")
+
+// ErrInvalidSort is returned for sort keys outside the whitelist
+var ErrInvalidSort = errors.New("pagination: invalid sort")
+
+// Kind is the type of a sortable column, needed to decode cursor values
+type Kind int
+
+const (
+	KindInt Kind = iota
+	KindString
+	KindTime
+)
+
+// Column is one whitelisted sort column
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// Sort is a parsed "?sort=" value such as "-date_added"
+type Sort struct {
+	Key        string
+	Column     Column
+	Descending bool
+}
+
+// Cursor marks the last row of a page: its sort value and its primary key as tie-breaker
+type Cursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	ID    uint        `json:"id"`
+}
+
+// ParseLimit reads "?limit=", defaulting to PAGE_SIZE_DEFAULT (20) and capped at PAGE_SIZE_MAX (100)
+func ParseLimit(raw string) (int, error) {
+	if raw == "" {
+		return env.Int("PAGE_SIZE_DEFAULT", 20), nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > env.Int("PAGE_SIZE_MAX", 100) {
+		return 0, errors.New("pagination: invalid limit")
+	}
+	return limit, nil
+}
+
+// ParseSort validates "?sort=" against the whitelist. A leading "-" sorts descending.
+func ParseSort(raw, def string, columns map[string]Column) (*Sort, error) {
+	if raw == "" {
+		raw = def
+	}
+	key := strings.TrimPrefix(raw, "-")
+	column, ok := columns[key]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+	return &Sort{Key: raw, Column: column, Descending: strings.HasPrefix(raw, "-")}, nil
+}
+
+// DecodeCursor parses an opaque cursor produced by Encode for the same sort
+func DecodeCursor(raw string, sort *Sort) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort.Key {
+		return nil, ErrInvalidCursor
+	}
+
+	// JSON loses the column type; restore it so the comparison uses the right SQL type
+	switch sort.Column.Kind {
+	case KindInt:
+		number, ok := cursor.Value.(float64)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		cursor.Value = int64(number)
+	case KindString:
+		if _, ok := cursor.Value.(string); !ok {
+			return nil, ErrInvalidCursor
+		}
+	case KindTime:
+		text, ok := cursor.Value.(string)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		if cursor.Value, err = time.Parse(time.RFC3339Nano, text); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
+
+// Encode returns the opaque cursor string
+func (c Cursor) Encode() string {
+	if t, ok := c.Value.(time.Time); ok {
+		c.Value = t.Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Apply adds ORDER BY, the keyset condition for the cursor and LIMIT (one extra row
+// to detect whether another page exists). idColumn breaks ties between equal sort values.
+func Apply(query *gorm.DB, sort *Sort, cursor *Cursor, limit int, idColumn string) *gorm.DB {
+	direction, comparison := "ASC", ">"
+	if sort.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if cursor != nil {
+		query = query.Where("("+sort.Column.Name+", "+idColumn+") "+comparison+" (?, ?)", cursor.Value, cursor.ID)
+	}
+	return query.
+		Order(sort.Column.Name + " " + direction + ", " + idColumn + " " + direction).
+		Limit(limit + 1)
+}
+
+// SetNextPage advertises the next page through a Link header (RFC 8288) and X-Next-Cursor.
+// The body stays a plain JSON array so existing clients keep working.
+func SetNextPage(c *gin.Context, next *Cursor) {
+	if next == nil {
+		return
+	}
+	encoded := next.Encode()
+
+	query := c.Request.URL.Query()
+	query.Set("cursor", encoded)
+	link := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+
+	c.Header("Link", "<"+link.String()+`>; rel="next"`)
+	c.Header("X-Next-Cursor", encoded)
+}
+
+// CheckQuery rejects unknown and repeated query parameters, keeping the API strict
+func CheckQuery(query url.Values, allowed ...string) bool {
+	for name, values := range query {
+		if len(values) != 1 {
+			return false
+		}
+		known := false
+		for _, candidate := range allowed {
+			if name == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package policy
+
+import (
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"my-project/env"
+	"my-project/logs"
+	"my-project/models"
+)
+
+// BootstrapAdmins gives RoleAdmin to the accounts listed in ADMIN_USERNAMES (comma separated,
+// case-insensitive). Before roles existed that list was the only way to be an admin, so running
+// this at startup carries those operators over and gives a fresh deployment its first admin.
+//
+// It only ever promotes: taking a name off the list does not demote anyone (use
+// PUT /v1/admin/users/:userId/role). Only verified accounts are promoted, so nobody can claim an
+// operator's address by registering it first; names without an account or with an unverified one
+// are skipped with a warning. Returns how many users were promoted.
+func BootstrapAdmins(database *gorm.DB) (int64, error) {
+	usernames := env.List("ADMIN_USERNAMES")
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+	for i := range usernames {
+		usernames[i] = strings.ToLower(usernames[i])
+	}
+
+	var listed []models.User
+	if err := database.Select("username", "verified").Where("LOWER(username) IN ?", usernames).Find(&listed).Error; err != nil {
+		return 0, err
+	}
+	verified := map[string]bool{}
+	for _, user := range listed {
+		verified[strings.ToLower(user.Username)] = user.Verified
+	}
+	for _, username := range usernames {
+		ok, exists := verified[username]
+		if !exists {
+			logs.Warn("ADMIN_USERNAMES lists " + username + " but there is no such account")
+		} else if !ok {
+			logs.Warn("ADMIN_USERNAMES lists " + username + " but the account is not verified, not promoting it")
+		}
+	}
+
+	result := database.Model(&models.User{}).
+		Where("LOWER(username) IN ? AND verified = ? AND role <> ?", usernames, true, RoleAdmin).
+		Update("role", RoleAdmin)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		logs.Info("Promoted " + strconv.FormatInt(result.RowsAffected, 10) + " users from ADMIN_USERNAMES to " + RoleAdmin)
+	}
+	return result.RowsAffected, nil
+}
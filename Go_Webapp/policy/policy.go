@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"my-project/models"
+)
+
+// Roles stored in users.role
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleAuditor = "auditor"
+)
+
+// Permission names an action. The "resource:action" form doubles as API key scope names.
+type Permission string
+
+const (
+	ProductRead  Permission = "product:read"
+	ProductWrite Permission = "product:write"
+
+	// ImageWrite covers uploading, deleting and restoring images. There is no image read
+	// permission: image reads are public, like product reads.
+	ImageWrite Permission = "image:write"
+
+	// UserReadAny allows reading other users' profiles
+	UserReadAny Permission = "user:read_any"
+
+	// UserAdmin covers account administration (unlocking, changing roles)
+	UserAdmin Permission = "user:admin"
+
+	// ManageAny lifts the ownership restriction on products and images
+	ManageAny Permission = "resource:manage_any"
+)
+
+// rolePermissions is the whole policy: what each role may do
+var rolePermissions = map[string][]Permission{
+	RoleUser: {
+		ProductRead, ProductWrite, ImageWrite,
+	},
+	RoleAuditor: {
+		ProductRead, UserReadAny,
+	},
+	RoleAdmin: {
+		ProductRead, ProductWrite, ImageWrite, UserReadAny, UserAdmin, ManageAny,
+	},
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Allows reports whether the user's role grants the permission.
+// Users with an empty role (rows created before roles existed) are treated as RoleUser.
+func Allows(user *models.User, permission Permission) bool {
+	role := user.Role
+	if role == "" {
+		role = RoleUser
+	}
+
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManage reports whether the user may modify a resource owned by ownerID:
+// owners can manage their own products and images, admins can manage anyone's.
+func CanManage(user *models.User, ownerID uint) bool {
+	return user.ID == ownerID || Allows(user, ManageAny)
+}
+
+// delegable are the permissions an API key may carry. Account administration
+// and cross-user reads stay with interactive logins.
+var delegable = []Permission{ProductRead, ProductWrite, ImageWrite}
+
+// ValidScope reports whether scope may be granted to an API key
+func ValidScope(scope string) bool {
+	for _, permission := range delegable {
+		if string(permission) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeAllows reports whether a key's scopes include the permission
+func ScopeAllows(scopes []string, permission Permission) bool {
+	for _, scope := range scopes {
+		if scope == string(permission) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows at most Limit events per key inside a fixed Window.
+// State is kept in memory, so each instance behind the load balancer counts separately.
+type Limiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// New creates a limiter allowing limit events per key in every period of length per
+func New(limit int, per time.Duration) *Limiter {
+	return &Limiter{Limit: limit, Window: per, windows: make(map[string]*window)}
+}
+
+// Allow records an event for key. When the key is over its limit it returns false
+// and how long the caller has to wait before the window resets.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.Window {
+		l.windows[key] = &window{start: now, count: 1}
+		return true, 0
+	}
+
+	if w.count >= l.Limit {
+		return false, w.start.Add(l.Window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// Reset forgets every event recorded for key
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.windows, key)
+}
+
+// sweep drops expired windows (at most once per window) so the map does not grow forever
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.Window {
+		return
+	}
+	l.lastSweep = now
+
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.Window {
+			delete(l.windows, key)
+		}
+	}
+}
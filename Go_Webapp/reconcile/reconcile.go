@@ -0,0 +1,225 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"my-project/models"
+	"my-project/storage"
+)
+
+// Options controls one reconciliation run
+type Options struct {
+	// Prefix limits the run to keys under it ("" is the whole bucket, "42/" one user)
+	Prefix string
+
+	// MinAge skips objects younger than this. CreateImage uploads before it inserts the row,
+	// so a fresh object without a row is usually an upload in progress, not an orphan.
+	MinAge time.Duration
+
+	// Repair deletes orphaned objects and flags dangling rows (images.missing_at)
+	Repair bool
+}
+
+// Orphan is a stored object no image row refers to
+type Orphan struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Deleted      bool      `json:"deleted,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Dangling is an image row whose object is missing
+type Dangling struct {
+	ImageID   uint   `json:"image_id"`
+	ProductID uint   `json:"product_id"`
+	Key       string `json:"key"`
+	Flagged   bool   `json:"flagged,omitempty"`
+}
+
+// Report is the drift found (and repaired) by Run. It is printed as JSON by cmd/reconcile.
+type Report struct {
+	Prefix         string     `json:"prefix"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     time.Time  `json:"finished_at"`
+	ObjectsScanned int        `json:"objects_scanned"`
+	RowsScanned    int        `json:"rows_scanned"`
+	Orphans        []Orphan   `json:"orphans"`
+	Dangling       []Dangling `json:"dangling"`
+
+	// Rows flagged missing by an earlier run whose object is back
+	Recovered []uint `json:"recovered"`
+}
+
+// batchSize is how many image rows are read at a time
+const batchSize = 500
+
+// Run compares the objects under opts.Prefix with the image table.
+//
+// Objects owned by something other than an image row are not orphans: data export archives
+// ("{userId}/exports/"), keys already queued in the storage deletion outbox, and prefixes of
+// account deletions still in progress. Trashed image rows still own their objects.
+func Run(ctx context.Context, database *gorm.DB, store storage.ObjectStore, opts Options) (*Report, error) {
+	report := &Report{Prefix: opts.Prefix, StartedAt: time.Now(), Orphans: []Orphan{}, Dangling: []Dangling{}, Recovered: []uint{}}
+
+	// 1. Everything in the bucket under the prefix
+	objects, err := store.List(ctx, opts.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	report.ObjectsScanned = len(objects)
+
+	stored := make(map[string]storage.ObjectInfo, len(objects))
+	for _, object := range objects {
+		stored[object.Key] = object
+	}
+
+	// 2. Keys owned by someone else than an image row
+	skip, skipPrefixes, err := ownedElsewhere(database, opts.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Walk the image rows: referenced objects are accounted for, missing ones are dangling
+	var rows []models.Image
+	err = database.Unscoped().Model(&models.Image{}).
+		Where("s3_bucket_path LIKE ?", likePrefix(opts.Prefix)).
+		FindInBatches(&rows, batchSize, func(tx *gorm.DB, batch int) error {
+			report.RowsScanned += len(rows)
+			for _, image := range rows {
+				if _, ok := stored[image.S3BucketPath]; ok {
+					delete(stored, image.S3BucketPath)
+					if image.MissingAt != nil {
+						report.Recovered = append(report.Recovered, image.ImageID)
+					}
+					continue
+				}
+				// Trashed rows are on their way out; a missing object does not matter there
+				if image.DeletedAt.Valid {
+					continue
+				}
+				// The listing is a snapshot: CreateImage may have uploaded the object and inserted
+				// the row since, so check the key itself before calling the row dangling
+				if _, err := store.Head(ctx, image.S3BucketPath); err == nil {
+					continue
+				} else if !errors.Is(err, storage.ErrNotFound) {
+					return err
+				}
+				report.Dangling = append(report.Dangling, Dangling{
+					ImageID: image.ImageID, ProductID: image.ProductID, Key: image.S3BucketPath,
+				})
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Renditions own their objects too. A missing rendition is not reported: the image is
+	// still usable and its renditions can be regenerated.
+	var renditionKeys []string
+	if err := database.Model(&models.ImageRendition{}).
+		Where("s3_bucket_path LIKE ?", likePrefix(opts.Prefix)).
+		Pluck("s3_bucket_path", &renditionKeys).Error; err != nil {
+		return nil, err
+	}
+	for _, key := range renditionKeys {
+		delete(stored, key)
+	}
+
+	// 5. Whatever is left in the listing has no row
+	cutoff := report.StartedAt.Add(-opts.MinAge)
+	for key, object := range stored {
+		if skip[key] || hasAnyPrefix(key, skipPrefixes) || isExportKey(key) || object.LastModified.After(cutoff) {
+			continue
+		}
+		report.Orphans = append(report.Orphans, Orphan{Key: key, Size: object.Size, LastModified: object.LastModified})
+	}
+
+	if opts.Repair {
+		if err := repair(ctx, database, store, report); err != nil {
+			return nil, err
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// repair deletes orphans, flags dangling rows and clears the flag on recovered ones.
+// A failed object delete is recorded on the orphan and does not stop the run.
+func repair(ctx context.Context, database *gorm.DB, store storage.ObjectStore, report *Report) error {
+	for i := range report.Orphans {
+		if err := store.Delete(ctx, report.Orphans[i].Key); err != nil {
+			report.Orphans[i].Error = err.Error()
+			continue
+		}
+		report.Orphans[i].Deleted = true
+	}
+
+	now := time.Now()
+	for i := range report.Dangling {
+		err := database.Model(&models.Image{}).
+			Where("image_id = ? AND missing_at IS NULL", report.Dangling[i].ImageID).
+			Update("missing_at", now).Error
+		if err != nil {
+			return err
+		}
+		report.Dangling[i].Flagged = true
+	}
+
+	if len(report.Recovered) > 0 {
+		return database.Unscoped().Model(&models.Image{}).
+			Where("image_id IN ?", report.Recovered).
+			Update("missing_at", nil).Error
+	}
+	return nil
+}
+
+// ownedElsewhere returns the keys queued for deletion and the prefixes of running account deletions
+func ownedElsewhere(database *gorm.DB, prefix string) (map[string]bool, []string, error) {
+	var queued []string
+	if err := database.Model(&models.StorageDeletion{}).
+		Where("object_key LIKE ?", likePrefix(prefix)).
+		Pluck("object_key", &queued).Error; err != nil {
+		return nil, nil, err
+	}
+	skip := make(map[string]bool, len(queued))
+	for _, key := range queued {
+		skip[key] = true
+	}
+
+	var prefixes []string
+	if err := database.Model(&models.AccountDeletion{}).
+		Where("status <> ?", models.DeletionCompleted).
+		Pluck("storage_prefix", &prefixes).Error; err != nil {
+		return nil, nil, err
+	}
+	return skip, prefixes, nil
+}
+
+// isExportKey matches data export archives: "{userId}/exports/{id}.zip"
+func isExportKey(key string) bool {
+	parts := strings.SplitN(key, "/", 3)
+	return len(parts) == 3 && parts[1] == "exports"
+}
+
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// likePrefix escapes prefix for a LIKE 'prefix%' match
+func likePrefix(prefix string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+	return escaped + "%"
+}
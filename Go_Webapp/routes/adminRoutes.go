@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"my-project/controllers"
+	"my-project/middleware"
+	"my-project/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes registers operator-only endpoints under /v1/admin.
+// Every route requires authentication and admin rights.
+func RegisterAdminRoutes(router *gin.RouterGroup) {
+	router.Use(middleware.AuthenticateUser(), middleware.RequirePermission(policy.UserAdmin))
+
+	// 1. Unlock a locked out username
+	router.DELETE("/lockouts/:username", controllers.UnlockUser)
+
+	// 2. Change a user's role (user, admin, auditor)
+	router.PUT("/users/:userId/role", controllers.UpdateUserRole)
+
+	// 3. Re-queue an account deletion whose storage cleanup failed
+	router.POST("/deletions/:deletionId/retry", controllers.RetryAccountDeletion)
+
+	// 4. Global image listing (paged, optional ?product_id=)
+	router.GET("/images", controllers.GetAllImageAdmin)
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"my-project/controllers"
+	"my-project/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAPIKeyRoutes registers key management for the authenticated user under /v1/apiKey.
+// Keys cannot manage keys: these routes only accept interactive credentials.
+func RegisterAPIKeyRoutes(router *gin.RouterGroup) {
+	router.Use(middleware.AuthenticateUser(), middleware.RejectAPIKeys())
+
+	// 1. Mint a key (raw key returned once)
+	router.POST("/", controllers.CreateAPIKey)
+
+	// 2. List own keys
+	router.GET("/", controllers.GetAllAPIKey)
+
+	// 3. Revoke a key
+	router.DELETE("/:keyId", controllers.DeleteAPIKey)
+}
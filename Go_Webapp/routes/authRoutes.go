@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"my-project/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAuthRoutes registers the token endpoints under /v1/auth.
+func RegisterAuthRoutes(router *gin.RouterGroup) {
+
+	// 1. Issue Tokens (Public, credentials or refresh token in the body)
+	router.POST("/token", controllers.CreateToken)
+
+	// 2. Revoke Refresh Token (Public, possession of the token is the proof)
+	router.POST("/revoke", controllers.RevokeToken)
+
+	// 3. Single Sign-On (OpenID Connect, authorization code + PKCE)
+	// login redirects to the identity provider, which redirects back to callback
+	router.GET("/oidc/login", controllers.OIDCLogin)
+	router.GET("/oidc/callback", controllers.OIDCCallback)
+}
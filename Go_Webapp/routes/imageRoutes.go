@@ -3,6 +3,7 @@ package routes
 import (
 	"my-project/controllers" // Update with your actual module path
 	"my-project/middleware"
+	"my-project/policy"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,9 +18,9 @@ func RegisterImageRoutes(router *gin.RouterGroup) {
 	// 1. POST Image (Auth + File Upload)
 	// Node: router.post(..., authenticateUser, upload.single('file'), createImage)
 	// Go: The "upload" logic is handled INSIDE controllers.CreateImage
-	router.POST("/:productId/image", middleware.AuthenticateUser(), controllers.CreateImage)
+	router.POST("/:productId/image", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ImageWrite), controllers.CreateImage)
 
-	// 2. GET All Images (Public)
+	// 2. GET All Images of the product (Public, paged)
 	// Node: router.get(..., getAllImage)
 	router.GET("/:productId/image", controllers.GetAllImage)
 
@@ -29,7 +30,10 @@ func RegisterImageRoutes(router *gin.RouterGroup) {
 
 	// 4. DELETE Image (Auth)
 	// Node: router.delete(..., authenticateUser, deleteImage)
-	router.DELETE("/:productId/image/:imageId", middleware.AuthenticateUser(), controllers.DeleteImage)
+	router.DELETE("/:productId/image/:imageId", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ImageWrite), controllers.DeleteImage)
+
+	// 4b. Restore a deleted image from the trash (Auth)
+	router.POST("/:productId/image/:imageId/restore", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ImageWrite), controllers.RestoreImage)
 
 	// 5. OPTIONS (Auth)
 	// Node: router.options(..., authenticateUser, otherMethods)
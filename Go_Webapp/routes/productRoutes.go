@@ -3,16 +3,26 @@ package routes
 import (
 	"my-project/controllers" // Update with your actual module path
 	"my-project/middleware"
+	"my-project/policy"
 
 	"github.com/gin-gonic/gin"
 )
 
 // RegisterProductRoutes registers the CRUD endpoints for products.
+// Writes need policy.ProductWrite; ownership is checked in the controllers.
 func RegisterProductRoutes(router *gin.RouterGroup) {
 
 	// 1. Create Product (Auth required)
 	// Node: router.post("/", authenticateUser, createProduct)
-	router.POST("/", middleware.AuthenticateUser(), controllers.CreateProduct)
+	router.POST("/", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ProductWrite), controllers.CreateProduct)
+
+	// 1b. Full-Text Search (Public)
+	// ?q= with prefix matching, ranked, with highlighted snippets
+	router.GET("/search", controllers.SearchProduct)
+
+	// 1c. Trash: the caller's deleted products, restorable until purged (Auth required)
+	router.GET("/trash", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ProductRead), controllers.GetProductTrash)
+	router.POST("/:productId/restore", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ProductWrite), controllers.RestoreProduct)
 
 	// 2. Get Single Product (Public)
 	// Node: router.get("/:productId", getProduct)
@@ -24,15 +34,15 @@ func RegisterProductRoutes(router *gin.RouterGroup) {
 
 	// 4. Update Product - PUT (Auth required)
 	// Node: router.put("/:productId", authenticateUser, updatePutProduct)
-	router.PUT("/:productId", middleware.AuthenticateUser(), controllers.UpdatePutProduct)
+	router.PUT("/:productId", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ProductWrite), controllers.UpdatePutProduct)
 
 	// 5. Update Product - PATCH (Auth required)
 	// Node: router.patch("/:productId", authenticateUser, updatePatchProduct)
-	router.PATCH("/:productId", middleware.AuthenticateUser(), controllers.UpdatePatchProduct)
+	router.PATCH("/:productId", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ProductWrite), controllers.UpdatePatchProduct)
 
 	// 6. Delete Product (Auth required)
 	// Node: router.delete("/:productId", authenticateUser, deleteProduct)
-	router.DELETE("/:productId", middleware.AuthenticateUser(), controllers.DeleteProduct)
+	router.DELETE("/:productId", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ProductWrite), controllers.DeleteProduct)
 
 	// 7. Options (Auth required)
 	// Node: router.options("/:productId", authenticateUser, otherMethods)
@@ -19,13 +19,37 @@ func RegisterUserRoutes(router *gin.RouterGroup) {
 	// Query params (like ?token=xyz) are handled inside the controller in Gin.
 	router.GET("/verifyEmail", controllers.VerifyEmail)
 
+	// 2b. Resend Verification Email (Public, throttled per address)
+	router.POST("/verifyEmail/resend", controllers.ResendVerification)
+
+	// 2c. Password Reset (Public)
+	// forgot: issues a one-time token delivered through SNS; reset: redeems it
+	router.POST("/password/forgot", controllers.ForgotPassword)
+	router.POST("/password/reset", controllers.ResetPassword)
+
 	// 3. Get User Details (Auth required)
 	// Node: router.get("/:userId", authenticateUser, getUser)
-	router.GET("/:userId", middleware.AuthenticateUser(), controllers.GetUser)
+	router.GET("/:userId", middleware.AuthenticateUser(), middleware.RejectAPIKeys(), controllers.GetUser)
 
 	// 4. Update User (Auth required)
 	// Node: router.put("/:userId", authenticateUser, updateUser)
-	router.PUT("/:userId", middleware.AuthenticateUser(), controllers.UpdateUser)
+	router.PUT("/:userId", middleware.AuthenticateUser(), middleware.RejectAPIKeys(), controllers.UpdateUser)
+
+	// 4a. Delete Account (Auth required). Storage cleanup runs in the background;
+	// its status resource is public because the account is gone by the time it is polled.
+	router.DELETE("/:userId", middleware.AuthenticateUser(), middleware.RejectAPIKeys(), controllers.DeleteUser)
+	router.GET("/deletions/:deletionId", controllers.GetAccountDeletion)
+
+	// 4b. Personal Data Export (Auth required, account owner only)
+	// The archive is built in the background; poll the status for the download link
+	router.POST("/:userId/export", middleware.AuthenticateUser(), middleware.RejectAPIKeys(), controllers.CreateDataExport)
+	router.GET("/:userId/export/:exportId", middleware.AuthenticateUser(), middleware.RejectAPIKeys(), controllers.GetDataExport)
+
+	// 4c. TOTP Multi-Factor Authentication (Auth required, account owner only)
+	// enroll returns the secret + otpauth URI; confirm enables MFA and returns recovery codes
+	router.POST("/:userId/mfa", middleware.AuthenticateUser(), middleware.RejectAPIKeys(), controllers.EnrollMFA)
+	router.POST("/:userId/mfa/confirm", middleware.AuthenticateUser(), middleware.RejectAPIKeys(), controllers.ConfirmMFA)
+	router.DELETE("/:userId/mfa", middleware.AuthenticateUser(), middleware.RejectAPIKeys(), controllers.DeleteMFA)
 
 	// 5. Other Methods (HEAD, OPTIONS, PATCH) - (Auth required)
 	// Node: router.head/options/patch("/:userId", authenticateUser, otherMethods)
@@ -136,6 +136,8 @@ DBHOST=${DB_HOST}
 DBNAME=${DB_NAME}
 DBPASSWORD=${DB_PASSWORD}
 DBPORT=${DB_PORT}
+JWT_SECRET=$(openssl rand -hex 32)
+VERIFY_TOKEN_SECRET=$(openssl rand -hex 32)
 EOF
 
 sudo chmod 600 ${APP_DIR}/.env
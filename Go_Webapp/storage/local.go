@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStore keeps objects as plain files under a root directory.
+// It is meant for laptops and CI, where there is no bucket to talk to.
+type LocalStore struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalStore creates the root directory if needed.
+// baseURL is optional; when empty, PresignGet returns file:// URLs. Whatever serves baseURL
+// serves every file under root to anyone who asks (see PresignGet).
+func NewLocalStore(root, baseURL string) (*LocalStore, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(absRoot, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{root: absRoot, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// pathFor maps an object key to a file path, refusing keys that escape the root
+func (s *LocalStore) pathFor(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || strings.HasSuffix(key, "/") || cleaned == "/" {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	filePath, err := s.pathFor(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file first so readers never see a half-written object
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := s.Head(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePath, _ := s.pathFor(key)
+	file, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	filePath, err := s.pathFor(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	filePath, err := s.pathFor(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, ErrNotFound
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         stat.Size(),
+		ContentType:  mime.TypeByExtension(path.Ext(key)),
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	// Only walk the directory the prefix points into: "42/" starts at 42, "42/exports/a" at 42/exports
+	start := s.root
+	if dir := path.Dir(prefix); dir != "." {
+		var err error
+		if start, err = s.pathFor(dir); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := os.Stat(start); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	err := filepath.WalkDir(start, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip directories and in-flight temp files from Put
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		stat, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			Size:         stat.Size(),
+			ContentType:  mime.TypeByExtension(path.Ext(key)),
+			LastModified: stat.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// PresignGet returns a plain link to the file. Unlike S3, nothing is signed and nothing enforces
+// the expiry: "?expires=" is informational only, and the link keeps working for as long as the file
+// exists. Do not point LOCAL_STORAGE_BASE_URL at a server reachable by other users.
+func (s *LocalStore) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	filePath, err := s.pathFor(key)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.Head(ctx, key); err != nil {
+		return "", err
+	}
+
+	// Nothing to sign locally; the expiry is carried along for parity with S3, not checked
+	if s.baseURL == "" {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(filePath)}).String(), nil
+	}
+	return fmt.Sprintf("%s/%s?expires=%d", s.baseURL, key, time.Now().Add(expires).Unix()), nil
+}
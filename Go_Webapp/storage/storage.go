@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// ErrNotFound is returned when the requested key does not exist in the store
+var ErrNotFound = errors.New("storage: object not found")
+
+// ObjectInfo describes a stored object without its contents
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// ObjectStore is the contract every storage backend (S3, local disk, ...) implements.
+// Controllers only talk to this interface, never to a concrete SDK client.
+type ObjectStore interface {
+	// Put uploads body under key, replacing any existing object
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+
+	// Get opens the object for reading. The caller must close the returned reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+
+	// Delete removes the object. Deleting a missing key is not an error (same as S3).
+	Delete(ctx context.Context, key string) error
+
+	// Head returns the object metadata, or ErrNotFound
+	Head(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// List returns every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+
+	// PresignGet returns a URL that allows downloading the object until expires elapses.
+	// Only S3Store enforces the expiry; LocalStore links are unsigned.
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// Store is the global object store instance (Equivalent to db.DB for files)
+var Store ObjectStore
+
+// InitializeStorage selects the backend from the environment.
+// STORAGE_BACKEND=s3 (default) uses S3_BUCKET_NAME, STORAGE_BACKEND=local uses LOCAL_STORAGE_DIR.
+func InitializeStorage() {
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "local":
+		dir := os.Getenv("LOCAL_STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+
+		store, err := NewLocalStore(dir, os.Getenv("LOCAL_STORAGE_BASE_URL"))
+		if err != nil {
+			log.Fatalf("Local storage initialization failed: %v", err)
+		}
+		Store = store
+		log.Println("Local object storage has been initialized at " + dir)
+
+	default:
+		store, err := NewS3Store(context.TODO(), os.Getenv("S3_BUCKET_NAME"), os.Getenv("AWS_REGION"))
+		if err != nil {
+			log.Fatalf("S3 storage initialization failed: %v", err)
+		}
+		Store = store
+		log.Println("S3 object storage has been initialized!")
+	}
+}
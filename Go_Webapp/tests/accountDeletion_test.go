@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm/clause"
+
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/jobs"
+	"my-project/middleware"
+	"my-project/models"
+	"my-project/storage"
+)
+
+// flakyStore fails the first Delete of each listed key, like an S3 outage halfway through
+type flakyStore struct {
+	storage.ObjectStore
+	failOnce map[string]bool
+}
+
+func (s *flakyStore) Delete(ctx context.Context, key string) error {
+	if s.failOnce[key] {
+		delete(s.failOnce, key)
+		return errors.New("simulated storage outage")
+	}
+	return s.ObjectStore.Delete(ctx, key)
+}
+
+// observedStore calls onGet/onDelete (when set) before each Get/Delete, to look at the database
+// while a job is busy
+type observedStore struct {
+	storage.ObjectStore
+	onGet    func(key string)
+	onDelete func(key string)
+}
+
+func (s *observedStore) Get(ctx context.Context, key string) (io.ReadCloser, *storage.ObjectInfo, error) {
+	if s.onGet != nil {
+		s.onGet(key)
+	}
+	return s.ObjectStore.Get(ctx, key)
+}
+
+func (s *observedStore) Delete(ctx context.Context, key string) error {
+	if s.onDelete != nil {
+		s.onDelete(key)
+	}
+	return s.ObjectStore.Delete(ctx, key)
+}
+
+func setupAccountDeletionTestEnv(t *testing.T) (*gin.Engine, *models.User, *models.User) {
+	testDB := db.DB
+	testDB.Exec("DELETE FROM account_deletions")
+	testDB.Exec("DELETE FROM image")
+	testDB.Exec("DELETE FROM product")
+	testDB.Exec("DELETE FROM users")
+
+	local, err := storage.NewLocalStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	storage.Store = local
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	users := make([]*models.User, 2)
+	for i, name := range []string{"leaving", "staying"} {
+		user := models.User{
+			Username: name + "@example.com", Password: string(hashed), FirstName: name, LastName: "User", Verified: true,
+		}
+		testDB.Create(&user)
+		users[i] = &user
+
+		product := models.Product{
+			Name: "P", Description: "D", Sku: name, Manufacturer: "M", Quantity: 1, OwnerUserID: user.ID,
+		}
+		testDB.Create(&product)
+
+		for _, file := range []string{"a.png", "b.png"} {
+			key := fmt.Sprintf("%d/%d/%s", user.ID, product.ID, file)
+			storage.Store.Put(context.Background(), key, strings.NewReader("img"), "image/png")
+			testDB.Create(&models.Image{ProductID: product.ID, FileName: file, S3BucketPath: key})
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.DELETE("/v1/user/:userId", middleware.AuthenticateUser(), controllers.DeleteUser)
+	r.GET("/v1/user/deletions/:deletionId", controllers.GetAccountDeletion)
+	return r, users[0], users[1]
+}
+
+func TestAccountDeletion(t *testing.T) {
+	router, leaving, staying := setupAccountDeletionTestEnv(t)
+	ctx := context.Background()
+
+	deleteAccount := func(as *models.User, id uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/v1/user/%d", id), nil)
+		req.SetBasicAuth(as.Username, "password123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	status := func(location string) models.AccountDeletion {
+		req, _ := http.NewRequest("GET", location, bytes.NewBuffer(nil))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		// The status endpoint is unauthenticated: it must not name the deleted account
+		assert.NotContains(t, w.Body.String(), leaving.Username)
+		assert.NotContains(t, w.Body.String(), "username")
+
+		var deletion models.AccountDeletion
+		json.Unmarshal(w.Body.Bytes(), &deletion)
+		return deletion
+	}
+
+	t.Run("should not delete another user's account", func(t *testing.T) {
+		assert.Equal(t, 403, deleteAccount(leaving, staying.ID).Code)
+	})
+
+	w := deleteAccount(leaving, leaving.ID)
+	assert.Equal(t, 202, w.Code)
+	location := w.Header().Get("Location")
+
+	t.Run("should remove the database rows immediately", func(t *testing.T) {
+		var count int64
+		db.DB.Model(&models.User{}).Where("id = ?", leaving.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+		db.DB.Model(&models.Product{}).Where("owner_user_id = ?", leaving.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+		db.DB.Model(&models.Product{}).Where("owner_user_id = ?", staying.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		assert.Equal(t, models.DeletionPending, status(location).Status)
+	})
+
+	t.Run("should resume after a storage failure", func(t *testing.T) {
+		objects, _ := storage.Store.List(ctx, fmt.Sprintf("%d/", leaving.ID))
+		assert.Len(t, objects, 2)
+
+		local := storage.Store
+		storage.Store = &flakyStore{ObjectStore: local, failOnce: map[string]bool{objects[1].Key: true}}
+		t.Cleanup(func() { storage.Store = local })
+
+		assert.NoError(t, jobs.ProcessAccountDeletions(ctx))
+		deletion := status(location)
+		assert.Equal(t, models.DeletionPending, deletion.Status)
+		assert.Equal(t, 1, deletion.ObjectsDeleted)
+
+		// Make the retry due now instead of after the backoff
+		db.DB.Model(&models.AccountDeletion{}).Where("status = ?", models.DeletionPending).Update("next_attempt_at", time.Now())
+		assert.NoError(t, jobs.ProcessAccountDeletions(ctx))
+
+		deletion = status(location)
+		assert.Equal(t, models.DeletionCompleted, deletion.Status)
+		assert.Equal(t, 2, deletion.ObjectsDeleted)
+		assert.Equal(t, 2, deletion.Attempts)
+
+		remaining, _ := local.List(ctx, fmt.Sprintf("%d/", leaving.ID))
+		assert.Empty(t, remaining)
+		untouched, _ := local.List(ctx, fmt.Sprintf("%d/", staying.ID))
+		assert.Len(t, untouched, 2)
+	})
+
+	t.Run("should lease the row instead of locking it during storage calls", func(t *testing.T) {
+		prefix := "leased/"
+		storage.Store.Put(ctx, prefix+"object.png", bytes.NewReader([]byte("bytes")), "image/png")
+		leased := models.AccountDeletion{
+			ID: "leased-deletion", Username: "gone@example.com", StoragePrefix: prefix,
+			Status: models.DeletionPending, NextAttemptAt: time.Now(),
+		}
+		db.DB.Create(&leased)
+
+		local := storage.Store
+		observed := false
+		storage.Store = &observedStore{ObjectStore: local, onDelete: func(key string) {
+			// NOWAIT fails right away if the job still held the row lock
+			var row models.AccountDeletion
+			err := db.DB.Clauses(clause.Locking{Strength: "UPDATE", Options: "NOWAIT"}).Where("id = ?", leased.ID).First(&row).Error
+			assert.NoError(t, err)
+			assert.True(t, row.NextAttemptAt.After(time.Now()), "row should be leased past now")
+			assert.Equal(t, 1, row.Attempts)
+			observed = true
+		}}
+		t.Cleanup(func() { storage.Store = local })
+
+		assert.NoError(t, jobs.ProcessAccountDeletions(ctx))
+		assert.True(t, observed)
+		assert.Equal(t, models.DeletionCompleted, status("/v1/user/deletions/"+leased.ID).Status)
+	})
+}
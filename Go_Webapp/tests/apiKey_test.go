@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/middleware"
+	"my-project/models"
+	"my-project/policy"
+)
+
+func setupAPIKeyTestEnv() (*gin.Engine, *models.User) {
+	testDB := db.DB
+	testDB.Exec("DELETE FROM api_keys")
+	testDB.Exec("DELETE FROM image")
+	testDB.Exec("DELETE FROM product")
+	testDB.Exec("DELETE FROM users")
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	user := models.User{
+		Username: "keys@example.com", Password: string(hashed), FirstName: "Key", LastName: "User", Verified: true,
+	}
+	testDB.Create(&user)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	keys := r.Group("/v1/apiKey")
+	keys.Use(middleware.AuthenticateUser(), middleware.RejectAPIKeys())
+	keys.POST("/", controllers.CreateAPIKey)
+	keys.GET("/", controllers.GetAllAPIKey)
+	keys.DELETE("/:keyId", controllers.DeleteAPIKey)
+
+	r.POST("/v1/product/", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ProductWrite), controllers.CreateProduct)
+
+	return r, &user
+}
+
+func TestAPIKeys(t *testing.T) {
+	router, user := setupAPIKeyTestEnv()
+
+	mint := func(scopes ...string) controllers.APIKeyResponse {
+		body, _ := json.Marshal(map[string]interface{}{"name": "ci", "scopes": scopes})
+		req, _ := http.NewRequest("POST", "/v1/apiKey/", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(user.Username, "password123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 201, w.Code)
+
+		var key controllers.APIKeyResponse
+		json.Unmarshal(w.Body.Bytes(), &key)
+		return key
+	}
+
+	createProduct := func(sku string, setAuth func(*http.Request)) int {
+		body, _ := json.Marshal(map[string]interface{}{
+			"name": "Keyed", "description": "D", "sku": sku, "manufacturer": "M", "quantity": 1,
+		})
+		req, _ := http.NewRequest("POST", "/v1/product/", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		setAuth(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	writer := mint(string(policy.ProductWrite))
+	reader := mint(string(policy.ProductRead))
+
+	t.Run("should return the raw key once and store only its hash", func(t *testing.T) {
+		assert.NotEmpty(t, writer.Key)
+
+		var stored models.APIKey
+		db.DB.First(&stored, writer.ID)
+		assert.NotEqual(t, writer.Key, stored.KeyHash)
+		assert.Nil(t, stored.LastUsedAt)
+	})
+
+	t.Run("should accept a key with the right scope via X-API-Key and Bearer", func(t *testing.T) {
+		assert.Equal(t, 201, createProduct("KEY-001", func(r *http.Request) { r.Header.Set("X-API-Key", writer.Key) }))
+		assert.Equal(t, 201, createProduct("KEY-002", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+writer.Key) }))
+
+		var stored models.APIKey
+		db.DB.First(&stored, writer.ID)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	t.Run("should return 403 when the key lacks the scope", func(t *testing.T) {
+		assert.Equal(t, 403, createProduct("KEY-003", func(r *http.Request) { r.Header.Set("X-API-Key", reader.Key) }))
+	})
+
+	t.Run("should reject scopes that cannot be delegated", func(t *testing.T) {
+		for _, scope := range []policy.Permission{policy.UserAdmin, "image:read"} {
+			body, _ := json.Marshal(map[string]interface{}{"name": "nope", "scopes": []string{string(scope)}})
+			req, _ := http.NewRequest("POST", "/v1/apiKey/", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetBasicAuth(user.Username, "password123")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 400, w.Code, string(scope))
+		}
+	})
+
+	t.Run("should not let a key manage keys", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/v1/apiKey/", nil)
+		req.Header.Set("X-API-Key", writer.Key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 403, w.Code)
+	})
+
+	t.Run("should list keys without secrets", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/v1/apiKey/", nil)
+		req.SetBasicAuth(user.Username, "password123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		assert.NotContains(t, w.Body.String(), writer.Key)
+
+		var keys []controllers.APIKeyResponse
+		json.Unmarshal(w.Body.Bytes(), &keys)
+		assert.Len(t, keys, 2)
+	})
+
+	t.Run("should reject a revoked key", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/v1/apiKey/%d", writer.ID), nil)
+		req.SetBasicAuth(user.Username, "password123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 204, w.Code)
+
+		assert.Equal(t, 401, createProduct("KEY-004", func(r *http.Request) { r.Header.Set("X-API-Key", writer.Key) }))
+	})
+}
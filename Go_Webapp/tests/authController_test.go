@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"my-project/auth"
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/middleware"
+	"my-project/models"
+)
+
+func setupAuthTestEnv() (*gin.Engine, *models.User, *gorm.DB) {
+	testDB := db.DB
+
+	// 1. Clean DB
+	testDB.Exec("DELETE FROM refresh_tokens")
+	testDB.Exec("DELETE FROM users")
+
+	// 2. Create User with KNOWN Password
+	password := "password123"
+	hashedPwd, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	user := models.User{
+		Username:  "auth.test@example.com",
+		Password:  string(hashedPwd),
+		FirstName: "Auth",
+		LastName:  "User",
+		Verified:  true,
+	}
+	testDB.Create(&user)
+
+	// 3. Setup Router
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	v1Auth := r.Group("/v1/auth")
+	v1Auth.POST("/token", controllers.CreateToken)
+	v1Auth.POST("/revoke", controllers.RevokeToken)
+
+	r.GET("/v1/user/:userId", middleware.AuthenticateUser(), controllers.GetUser)
+
+	user.Password = password
+	return r, &user, testDB
+}
+
+// postJSON sends a JSON body and returns the recorder
+func postJSON(router *gin.Engine, path string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthController(t *testing.T) {
+
+	t.Run("POST /v1/auth/token", func(t *testing.T) {
+		router, user, _ := setupAuthTestEnv()
+
+		t.Run("should issue a token pair for valid credentials", func(t *testing.T) {
+			w := postJSON(router, "/v1/auth/token", map[string]string{
+				"grant_type": "password", "username": user.Username, "password": user.Password,
+			})
+			assert.Equal(t, 200, w.Code)
+
+			var pair auth.TokenPair
+			json.Unmarshal(w.Body.Bytes(), &pair)
+			assert.Equal(t, "Bearer", pair.TokenType)
+			assert.NotEmpty(t, pair.AccessToken)
+			assert.NotEmpty(t, pair.RefreshToken)
+
+			// The access token must work in place of Basic Auth
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/user/%d", user.ID), nil)
+			req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code)
+		})
+
+		t.Run("should return 401 for a wrong password", func(t *testing.T) {
+			w := postJSON(router, "/v1/auth/token", map[string]string{
+				"grant_type": "password", "username": user.Username, "password": "wrongpassword",
+			})
+			assert.Equal(t, 401, w.Code)
+		})
+
+		t.Run("should return 400 for an unknown grant type", func(t *testing.T) {
+			w := postJSON(router, "/v1/auth/token", map[string]string{"grant_type": "client_credentials"})
+			assert.Equal(t, 400, w.Code)
+		})
+
+		t.Run("should return 401 for a forged bearer token", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/user/%d", user.ID), nil)
+			req.Header.Set("Authorization", "Bearer not.a.token")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 401, w.Code)
+		})
+	})
+
+	t.Run("refresh token rotation", func(t *testing.T) {
+		router, user, _ := setupAuthTestEnv()
+
+		w := postJSON(router, "/v1/auth/token", map[string]string{
+			"grant_type": "password", "username": user.Username, "password": user.Password,
+		})
+		var first auth.TokenPair
+		json.Unmarshal(w.Body.Bytes(), &first)
+
+		var second auth.TokenPair
+
+		t.Run("should rotate the refresh token", func(t *testing.T) {
+			w := postJSON(router, "/v1/auth/token", map[string]string{
+				"grant_type": "refresh_token", "refresh_token": first.RefreshToken,
+			})
+			assert.Equal(t, 200, w.Code)
+
+			json.Unmarshal(w.Body.Bytes(), &second)
+			assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+		})
+
+		t.Run("should reject reuse and revoke the whole family", func(t *testing.T) {
+			w := postJSON(router, "/v1/auth/token", map[string]string{
+				"grant_type": "refresh_token", "refresh_token": first.RefreshToken,
+			})
+			assert.Equal(t, 401, w.Code)
+
+			w = postJSON(router, "/v1/auth/token", map[string]string{
+				"grant_type": "refresh_token", "refresh_token": second.RefreshToken,
+			})
+			assert.Equal(t, 401, w.Code)
+		})
+	})
+
+	t.Run("POST /v1/auth/revoke", func(t *testing.T) {
+		router, user, _ := setupAuthTestEnv()
+
+		w := postJSON(router, "/v1/auth/token", map[string]string{
+			"grant_type": "password", "username": user.Username, "password": user.Password,
+		})
+		var pair auth.TokenPair
+		json.Unmarshal(w.Body.Bytes(), &pair)
+
+		t.Run("should revoke and return 204", func(t *testing.T) {
+			w := postJSON(router, "/v1/auth/revoke", map[string]string{"refresh_token": pair.RefreshToken})
+			assert.Equal(t, 204, w.Code)
+
+			w = postJSON(router, "/v1/auth/token", map[string]string{
+				"grant_type": "refresh_token", "refresh_token": pair.RefreshToken,
+			})
+			assert.Equal(t, 401, w.Code)
+		})
+	})
+}
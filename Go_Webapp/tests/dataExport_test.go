@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm/clause"
+
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/jobs"
+	"my-project/middleware"
+	"my-project/models"
+	"my-project/storage"
+)
+
+func setupDataExportTestEnv(t *testing.T) (*gin.Engine, *models.User) {
+	testDB := db.DB
+	testDB.Exec("DELETE FROM data_exports")
+	testDB.Exec("DELETE FROM image")
+	testDB.Exec("DELETE FROM product")
+	testDB.Exec("DELETE FROM users")
+
+	local, err := storage.NewLocalStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	storage.Store = local
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	user := models.User{
+		Username: "export@example.com", Password: string(hashed), FirstName: "Ex", LastName: "Port", Verified: true,
+	}
+	testDB.Create(&user)
+
+	product := models.Product{
+		Name: "Lamp", Description: "D", Sku: "EXP-001", Manufacturer: "M", Quantity: 1, OwnerUserID: user.ID,
+	}
+	testDB.Create(&product)
+
+	key := fmt.Sprintf("%d/%d/lamp.png", user.ID, product.ID)
+	storage.Store.Put(context.Background(), key, strings.NewReader("lamp pixels"), "image/png")
+	lamp := models.Image{ProductID: product.ID, FileName: "lamp.png", S3BucketPath: key}
+	testDB.Create(&lamp)
+	testDB.Create(&models.ImageRendition{
+		ImageID: lamp.ImageID, Name: "thumb", Width: 128, Height: 128, ContentType: "image/jpeg", Size: 10,
+		S3BucketPath: fmt.Sprintf("%d/%d/renditions/lamp-thumb.jpg", user.ID, product.ID),
+	})
+
+	// A product in the trash, with its image, still belongs in the export
+	trashed := models.Product{
+		Name: "Chair", Description: "D", Sku: "EXP-002", Manufacturer: "M", Quantity: 1, OwnerUserID: user.ID,
+	}
+	testDB.Create(&trashed)
+	trashedKey := fmt.Sprintf("%d/%d/chair.png", user.ID, trashed.ID)
+	storage.Store.Put(context.Background(), trashedKey, strings.NewReader("chair pixels"), "image/png")
+	testDB.Create(&models.Image{ProductID: trashed.ID, FileName: "chair.png", S3BucketPath: trashedKey})
+	deletedAt := time.Now()
+	testDB.Model(&models.Image{}).Where("product_id = ?", trashed.ID).Update("deleted_at", deletedAt)
+	testDB.Model(&models.Product{}).Where("id = ?", trashed.ID).Update("deleted_at", deletedAt)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/v1/user/:userId/export", middleware.AuthenticateUser(), controllers.CreateDataExport)
+	r.GET("/v1/user/:userId/export/:exportId", middleware.AuthenticateUser(), controllers.GetDataExport)
+	return r, &user
+}
+
+func TestDataExport(t *testing.T) {
+	router, user := setupDataExportTestEnv(t)
+	ctx := context.Background()
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.SetBasicAuth(user.Username, "password123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("POST", fmt.Sprintf("/v1/user/%d/export", user.ID))
+	assert.Equal(t, 202, w.Code)
+	location := w.Header().Get("Location")
+
+	t.Run("should refuse a second export while one is pending", func(t *testing.T) {
+		assert.Equal(t, 409, request("POST", fmt.Sprintf("/v1/user/%d/export", user.ID)).Code)
+	})
+
+	t.Run("should build the archive in the background", func(t *testing.T) {
+		assert.NoError(t, jobs.ProcessDataExports(ctx))
+
+		w := request("GET", location)
+		assert.Equal(t, 200, w.Code)
+
+		var response controllers.DataExportResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, models.ExportCompleted, response.Status)
+		assert.NotEmpty(t, response.DownloadURL)
+		assert.NotNil(t, response.ExpiresAt)
+
+		var export models.DataExport
+		db.DB.Where("user_id = ?", user.ID).First(&export)
+		body, _, err := storage.Store.Get(ctx, export.ObjectKey)
+		assert.NoError(t, err)
+		raw, _ := io.ReadAll(body)
+		body.Close()
+
+		archive, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+		assert.NoError(t, err)
+
+		files := map[string]string{}
+		for _, file := range archive.File {
+			reader, _ := file.Open()
+			content, _ := io.ReadAll(reader)
+			reader.Close()
+			files[file.Name] = string(content)
+		}
+		assert.Contains(t, files["user.json"], user.Username)
+		assert.NotContains(t, files["user.json"], "password")
+		assert.Contains(t, files["products.json"], "EXP-001")
+		assert.Contains(t, files, "manifest.json")
+
+		var exportedImages []models.Image
+		json.Unmarshal([]byte(files["images.json"]), &exportedImages)
+		assert.Len(t, exportedImages, 2)
+		for _, exported := range exportedImages {
+			if exported.FileName == "lamp.png" && assert.Len(t, exported.Renditions, 1) {
+				assert.Equal(t, "thumb", exported.Renditions[0].Name)
+			}
+		}
+
+		var image models.Image
+		db.DB.Where("file_name = ?", "lamp.png").First(&image)
+		assert.Equal(t, "lamp pixels", files["images/"+image.S3BucketPath])
+
+		// The trashed product and its image are exported too
+		assert.Contains(t, files["products.json"], "EXP-002")
+		var trashedImage models.Image
+		db.DB.Unscoped().Where("file_name = ?", "chair.png").First(&trashedImage)
+		assert.Equal(t, "chair pixels", files["images/"+trashedImage.S3BucketPath])
+	})
+
+	t.Run("should not show the export to other users", func(t *testing.T) {
+		assert.Equal(t, 403, request("GET", strings.Replace(location, fmt.Sprintf("/%d/", user.ID), "/999999/", 1)).Code)
+	})
+
+	t.Run("should lease the export instead of locking it while building", func(t *testing.T) {
+		w := request("POST", fmt.Sprintf("/v1/user/%d/export", user.ID))
+		assert.Equal(t, 202, w.Code)
+		var queued models.DataExport
+		json.Unmarshal(w.Body.Bytes(), &queued)
+
+		local := storage.Store
+		observed := false
+		storage.Store = &observedStore{ObjectStore: local, onGet: func(key string) {
+			// NOWAIT fails right away if the job still held the row lock
+			var export models.DataExport
+			err := db.DB.Clauses(clause.Locking{Strength: "UPDATE", Options: "NOWAIT"}).Where("id = ?", queued.ID).First(&export).Error
+			assert.NoError(t, err)
+			if assert.NotNil(t, export.LeasedUntil) {
+				assert.True(t, export.LeasedUntil.After(time.Now()), "export should be leased past now")
+			}
+			assert.Equal(t, 1, export.Attempts)
+			observed = true
+		}}
+		t.Cleanup(func() { storage.Store = local })
+
+		assert.NoError(t, jobs.ProcessDataExports(ctx))
+		assert.True(t, observed)
+
+		var export models.DataExport
+		db.DB.Where("id = ?", queued.ID).First(&export)
+		assert.Equal(t, models.ExportCompleted, export.Status)
+		assert.Nil(t, export.LeasedUntil)
+	})
+}
+
+func TestDataExportConcurrentRequests(t *testing.T) {
+	_, user := setupDataExportTestEnv(t)
+
+	// Several requests can pass the pending check at once; the unique index lets only one insert through
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = jobs.RequestDataExport(user)
+		}(i)
+	}
+	wg.Wait()
+
+	queued := 0
+	for _, err := range errs {
+		if err == nil {
+			queued++
+			continue
+		}
+		assert.ErrorIs(t, err, jobs.ErrExportInProgress)
+	}
+	assert.Equal(t, 1, queued)
+
+	var pending int64
+	db.DB.Model(&models.DataExport{}).Where("user_id = ? AND status = ?", user.ID, models.ExportPending).Count(&pending)
+	assert.Equal(t, int64(1), pending)
+}
@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/middleware"
+	"my-project/models"
+	"my-project/storage"
+)
+
+func setupImageTestEnv(t *testing.T) (*gin.Engine, *models.User, *models.Product, *gorm.DB) {
+	testDB := db.DB
+
+	// 1. Clean DB
+	testDB.Exec("DELETE FROM image")
+	testDB.Exec("DELETE FROM product")
+	testDB.Exec("DELETE FROM users")
+
+	// 2. Use the local-disk backend so no AWS access is needed
+	store, err := storage.NewLocalStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	storage.Store = store
+
+	// 3. Create User and Product
+	password := "password123"
+	hashedPwd, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	user := models.User{
+		Username:  "image.test@example.com",
+		Password:  string(hashedPwd),
+		FirstName: "Image",
+		LastName:  "User",
+		Verified:  true,
+	}
+	testDB.Create(&user)
+
+	product := models.Product{
+		Name: "Camera", Description: "Desc", Sku: "CAM-001",
+		Manufacturer: "Optics Inc.", Quantity: 5, OwnerUserID: user.ID,
+	}
+	testDB.Create(&product)
+
+	// 4. Setup Router
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	v1 := r.Group("/v1/product")
+	v1.GET("/:productId/image", controllers.GetAllImage)
+	v1.GET("/:productId/image/:imageId", controllers.GetImage)
+	v1.POST("/:productId/image", middleware.AuthenticateUser(), controllers.CreateImage)
+	v1.DELETE("/:productId/image/:imageId", middleware.AuthenticateUser(), controllers.DeleteImage)
+
+	user.Password = password
+	return r, &user, &product, testDB
+}
+
+// newImageUpload builds a multipart body with a single "file" part
+func newImageUpload(t *testing.T, fileName, contentType string, content []byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, fileName))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("Failed to create multipart part: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	return body, writer.FormDataContentType()
+}
+
+func TestImageController(t *testing.T) {
+
+	t.Run("POST /v1/product/:productId/image", func(t *testing.T) {
+		router, user, product, database := setupImageTestEnv(t)
+
+		t.Run("should store the file and return 201", func(t *testing.T) {
+			content := pngBytes(40, 30)
+			body, contentType := newImageUpload(t, "photo.png", "image/png", content)
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/v1/product/%d/image", product.ID), body)
+			req.Header.Set("Content-Type", contentType)
+			req.SetBasicAuth(user.Username, user.Password)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != 201 {
+				t.Logf("Failed Response Body: %s", w.Body.String())
+			}
+			assert.Equal(t, 201, w.Code)
+
+			var image models.Image
+			json.Unmarshal(w.Body.Bytes(), &image)
+
+			assert.Equal(t, "image/png", image.ContentType)
+			assert.Equal(t, 40, image.Width)
+			assert.Equal(t, 30, image.Height)
+
+			info, err := storage.Store.Head(context.Background(), image.S3BucketPath)
+			assert.NoError(t, err)
+			if info != nil {
+				assert.Equal(t, int64(len(content)), info.Size)
+			}
+
+			var count int64
+			database.Model(&models.Image{}).Where("product_id = ?", product.ID).Count(&count)
+			assert.Equal(t, int64(1), count)
+		})
+
+		t.Run("should return 400 for a non-image content type", func(t *testing.T) {
+			body, contentType := newImageUpload(t, "notes.txt", "text/plain", []byte("hello"))
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/v1/product/%d/image", product.ID), body)
+			req.Header.Set("Content-Type", contentType)
+			req.SetBasicAuth(user.Username, user.Password)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 400, w.Code)
+		})
+
+		upload := func(fileName, declared string, content []byte) *httptest.ResponseRecorder {
+			body, contentType := newImageUpload(t, fileName, declared, content)
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/v1/product/%d/image", product.ID), body)
+			req.Header.Set("Content-Type", contentType)
+			req.SetBasicAuth(user.Username, user.Password)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		t.Run("should return 400 for a forged image content type", func(t *testing.T) {
+			assert.Equal(t, 400, upload("evil.png", "image/png", []byte("<script>alert(1)</script>")).Code)
+			assert.Equal(t, 400, upload("truncated.png", "image/png", pngBytes(10, 10)[:20]).Code)
+		})
+
+		t.Run("should store the detected type rather than the declared one", func(t *testing.T) {
+			w := upload("mislabelled.jpg", "image/jpeg", pngBytes(8, 8))
+			assert.Equal(t, 201, w.Code)
+
+			var image models.Image
+			json.Unmarshal(w.Body.Bytes(), &image)
+			assert.Equal(t, "image/png", image.ContentType)
+		})
+
+		t.Run("should reject oversized dimensions and decompression bombs", func(t *testing.T) {
+			t.Setenv("IMAGE_MAX_WIDTH", "100")
+			assert.Equal(t, 400, upload("wide.png", "image/png", pngBytes(101, 10)).Code)
+
+			t.Setenv("IMAGE_MAX_PIXELS", "1000")
+			assert.Equal(t, 400, upload("bomb.png", "image/png", pngBytes(50, 50)).Code)
+		})
+
+		t.Run("should return 413 for files over the size limit", func(t *testing.T) {
+			t.Setenv("IMAGE_MAX_FILE_SIZE", "100")
+			assert.Equal(t, 413, upload("big.png", "image/png", pngBytes(200, 200)).Code)
+		})
+	})
+
+	t.Run("GET /v1/product/:productId/image", func(t *testing.T) {
+		router, user, product, database := setupImageTestEnv(t)
+
+		other := models.Product{
+			Name: "Tripod", Description: "Desc", Sku: "TRI-001", Manufacturer: "Optics Inc.", Quantity: 1, OwnerUserID: user.ID,
+		}
+		database.Create(&other)
+		for i := 0; i < 3; i++ {
+			database.Create(&models.Image{ProductID: product.ID, FileName: fmt.Sprintf("%d.png", i), S3BucketPath: fmt.Sprintf("k/%d", i)})
+		}
+		database.Create(&models.Image{ProductID: other.ID, FileName: "other.png", S3BucketPath: "k/other"})
+
+		list := func(path string) ([]models.Image, *httptest.ResponseRecorder) {
+			req, _ := http.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var images []models.Image
+			json.Unmarshal(w.Body.Bytes(), &images)
+			return images, w
+		}
+
+		t.Run("should only list images of the product, page by page", func(t *testing.T) {
+			images, w := list(fmt.Sprintf("/v1/product/%d/image?limit=2", product.ID))
+			assert.Equal(t, 200, w.Code)
+			assert.Len(t, images, 2)
+
+			next := w.Header().Get("X-Next-Cursor")
+			assert.NotEmpty(t, next)
+			images, w = list(fmt.Sprintf("/v1/product/%d/image?limit=2&cursor=%s", product.ID, next))
+			assert.Len(t, images, 1)
+			assert.Empty(t, w.Header().Get("X-Next-Cursor"))
+			assert.Equal(t, product.ID, images[0].ProductID)
+		})
+
+		t.Run("should return 404 for a nonexistent product", func(t *testing.T) {
+			_, w := list("/v1/product/999999/image")
+			assert.Equal(t, 404, w.Code)
+		})
+	})
+
+	t.Run("DELETE /v1/product/:productId/image/:imageId", func(t *testing.T) {
+		router, user, product, database := setupImageTestEnv(t)
+
+		key := fmt.Sprintf("%d/%d/to-delete.png", user.ID, product.ID)
+		storage.Store.Put(context.Background(), key, bytes.NewReader([]byte("bytes")), "image/png")
+		image := models.Image{ProductID: product.ID, FileName: "to-delete.png", S3BucketPath: key}
+		database.Create(&image)
+
+		t.Run("should move the image to the trash and return 204", func(t *testing.T) {
+			req, _ := http.NewRequest("DELETE", fmt.Sprintf("/v1/product/%d/image/%d", product.ID, image.ImageID), nil)
+			req.SetBasicAuth(user.Username, user.Password)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 204, w.Code)
+
+			req, _ = http.NewRequest("GET", fmt.Sprintf("/v1/product/%d/image/%d", product.ID, image.ImageID), nil)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 404, w.Code)
+
+			// The object is kept until the trash is purged
+			_, err := storage.Store.Head(context.Background(), key)
+			assert.NoError(t, err)
+		})
+	})
+}
@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/webp"
+	"gorm.io/gorm/clause"
+
+	"my-project/jobs"
+	"my-project/models"
+	"my-project/storage"
+)
+
+// pngBytes encodes a solid width x height PNG
+func pngBytes(width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 80, B: 40, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestImageRenditions(t *testing.T) {
+	t.Setenv("IMAGE_RENDITIONS", "thumb:128:jpeg,medium:512")
+	router, user, product, database := setupImageTestEnv(t)
+	ctx := context.Background()
+
+	upload := func(name string, content []byte) models.Image {
+		body, contentType := newImageUpload(t, name, "image/png", content)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/v1/product/%d/image", product.ID), body)
+		req.Header.Set("Content-Type", contentType)
+		req.SetBasicAuth(user.Username, user.Password)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 201, w.Code)
+
+		var created models.Image
+		json.Unmarshal(w.Body.Bytes(), &created)
+		return created
+	}
+
+	get := func(imageID uint) models.Image {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/product/%d/image/%d", product.ID, imageID), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var fetched models.Image
+		json.Unmarshal(w.Body.Bytes(), &fetched)
+		return fetched
+	}
+
+	t.Run("should generate the configured renditions next to the original", func(t *testing.T) {
+		created := upload("wide.png", pngBytes(1024, 256))
+		assert.Equal(t, models.RenditionsPending, created.RenditionStatus)
+
+		assert.NoError(t, jobs.ProcessImageRenditions(ctx))
+
+		fetched := get(created.ImageID)
+		assert.Equal(t, models.RenditionsCompleted, fetched.RenditionStatus)
+		if assert.Len(t, fetched.Renditions, 2) {
+			byName := map[string]models.ImageRendition{}
+			for _, rendition := range fetched.Renditions {
+				byName[rendition.Name] = rendition
+				assert.True(t, strings.HasPrefix(rendition.S3BucketPath, created.S3BucketPath))
+
+				info, err := storage.Store.Head(ctx, rendition.S3BucketPath)
+				assert.NoError(t, err)
+				if info != nil {
+					assert.Equal(t, rendition.Size, info.Size)
+				}
+			}
+
+			assert.Equal(t, 128, byName["thumb"].Width)
+			assert.Equal(t, 32, byName["thumb"].Height)
+			assert.Equal(t, "image/jpeg", byName["thumb"].ContentType)
+			assert.Equal(t, 512, byName["medium"].Width)
+			assert.Equal(t, "image/png", byName["medium"].ContentType)
+		}
+	})
+
+	t.Run("should not upscale small originals", func(t *testing.T) {
+		created := upload("small.png", pngBytes(64, 48))
+		assert.NoError(t, jobs.ProcessImageRenditions(ctx))
+
+		for _, rendition := range get(created.ImageID).Renditions {
+			assert.Equal(t, 64, rendition.Width)
+			assert.Equal(t, 48, rendition.Height)
+		}
+	})
+
+	t.Run("should mark undecodable originals as failed", func(t *testing.T) {
+		key := fmt.Sprintf("%d/%d/broken.png", user.ID, product.ID)
+		storage.Store.Put(ctx, key, strings.NewReader("not a png"), "image/png")
+		broken := models.Image{ProductID: product.ID, FileName: "broken.png", S3BucketPath: key}
+		database.Create(&broken)
+
+		assert.NoError(t, jobs.ProcessImageRenditions(ctx))
+
+		fetched := get(broken.ImageID)
+		assert.Equal(t, models.RenditionsFailed, fetched.RenditionStatus)
+		assert.Empty(t, fetched.Renditions)
+	})
+
+	t.Run("should encode webp renditions", func(t *testing.T) {
+		t.Setenv("IMAGE_RENDITIONS", "thumb:128:webp")
+		created := upload("photo.png", pngBytes(256, 256))
+		assert.NoError(t, jobs.ProcessImageRenditions(ctx))
+
+		renditions := get(created.ImageID).Renditions
+		if assert.Len(t, renditions, 1) {
+			assert.Equal(t, "image/webp", renditions[0].ContentType)
+			assert.True(t, strings.HasSuffix(renditions[0].S3BucketPath, ".thumb.webp"))
+
+			body, _, err := storage.Store.Get(ctx, renditions[0].S3BucketPath)
+			if assert.NoError(t, err) {
+				defer body.Close()
+				decoded, err := webp.DecodeConfig(body)
+				assert.NoError(t, err)
+				assert.Equal(t, 128, decoded.Width)
+			}
+		}
+	})
+
+	t.Run("should lease the image instead of locking it while rendering", func(t *testing.T) {
+		created := upload("leased.png", pngBytes(256, 256))
+
+		local := storage.Store
+		observed := false
+		storage.Store = &observedStore{ObjectStore: local, onGet: func(key string) {
+			// NOWAIT fails right away if the job still held the row lock
+			var image models.Image
+			err := database.Clauses(clause.Locking{Strength: "UPDATE", Options: "NOWAIT"}).First(&image, created.ImageID).Error
+			assert.NoError(t, err)
+			if assert.NotNil(t, image.RenditionLeasedUntil) {
+				assert.True(t, image.RenditionLeasedUntil.After(time.Now()), "image should be leased past now")
+			}
+			observed = true
+		}}
+		t.Cleanup(func() { storage.Store = local })
+
+		assert.NoError(t, jobs.ProcessImageRenditions(ctx))
+		assert.True(t, observed)
+
+		var image models.Image
+		database.First(&image, created.ImageID)
+		assert.Equal(t, models.RenditionsCompleted, image.RenditionStatus)
+		assert.Nil(t, image.RenditionLeasedUntil)
+	})
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"my-project/storage"
+)
+
+func TestLocalStoreList(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, key := range []string{"42/a.png", "42/exports/archive.zip", "43/b.png", "420/c.png"} {
+		assert.NoError(t, store.Put(ctx, key, strings.NewReader("x"), "image/png"))
+	}
+
+	keys := func(prefix string) []string {
+		objects, err := store.List(ctx, prefix)
+		assert.NoError(t, err)
+		result := []string{}
+		for _, object := range objects {
+			result = append(result, object.Key)
+		}
+		return result
+	}
+
+	t.Run("should list only keys under a directory prefix", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"42/a.png", "42/exports/archive.zip"}, keys("42/"))
+	})
+
+	t.Run("should match prefixes that end inside a name", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"42/exports/archive.zip"}, keys("42/ex"))
+		assert.ElementsMatch(t, []string{"42/a.png", "42/exports/archive.zip", "420/c.png"}, keys("42"))
+	})
+
+	t.Run("should return an empty listing for a missing directory", func(t *testing.T) {
+		assert.Empty(t, keys("7/"))
+		assert.Empty(t, keys("42/missing/"))
+	})
+
+	t.Run("should list everything without a prefix", func(t *testing.T) {
+		assert.Len(t, keys(""), 4)
+	})
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/middleware"
+	"my-project/models"
+	"my-project/policy"
+)
+
+func setupLockoutTestEnv(t *testing.T) (*gin.Engine, *models.User, *models.User) {
+	testDB := db.DB
+	testDB.Exec("DELETE FROM login_attempts")
+	testDB.Exec("DELETE FROM users")
+	t.Cleanup(func() { testDB.Exec("DELETE FROM login_attempts") })
+
+	t.Setenv("LOCKOUT_THRESHOLD", "3")
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	user := models.User{Username: "victim@example.com", Password: string(hashed), FirstName: "V", LastName: "U", Verified: true}
+	admin := models.User{Username: "admin@example.com", Password: string(hashed), FirstName: "A", LastName: "U", Verified: true, Role: policy.RoleAdmin}
+	testDB.Create(&user)
+	testDB.Create(&admin)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/v1/user/:userId", middleware.AuthenticateUser(), controllers.GetUser)
+	r.DELETE("/v1/admin/lockouts/:username", middleware.AuthenticateUser(), middleware.RequirePermission(policy.UserAdmin), controllers.UnlockUser)
+
+	return r, &user, &admin
+}
+
+func TestLockout(t *testing.T) {
+	router, user, admin := setupLockoutTestEnv(t)
+
+	get := func(username, password string, remoteAddr string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/user/%d", user.ID), nil)
+		req.RemoteAddr = remoteAddr
+		req.SetBasicAuth(username, password)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should lock the username after repeated failures", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, 401, get(user.Username, "wrong", "10.0.0.1:1000").Code)
+		}
+
+		// Even the correct password is refused while locked
+		w := get(user.Username, "password123", "10.0.0.2:1000")
+		assert.Equal(t, 429, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	})
+
+	t.Run("should reject unlock by a non-admin", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/v1/admin/lockouts/"+user.Username, nil)
+		req.RemoteAddr = "10.0.0.3:1000"
+		req.SetBasicAuth(user.Username, "password123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.NotEqual(t, 204, w.Code)
+	})
+
+	t.Run("should allow login again after an admin unlock", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/v1/admin/lockouts/"+user.Username, nil)
+		req.RemoteAddr = "10.0.0.3:1000"
+		req.SetBasicAuth(admin.Username, "password123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 204, w.Code)
+
+		assert.Equal(t, 200, get(user.Username, "password123", "10.0.0.2:1000").Code)
+	})
+}
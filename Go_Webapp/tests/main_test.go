@@ -8,6 +8,7 @@ import (
 
 	"my-project/db"
 	"my-project/logs"
+	"my-project/verify"
 
 	"github.com/joho/godotenv"
 )
@@ -28,13 +29,18 @@ func TestMain(m *testing.M) {
 		log.Fatal("Database connection is dead: ", err)
 	}
 
-	// 3. Init Metrics
+	// 3. Init Metrics (GO_ENV=test also lets JWT_SECRET and VERIFY_TOKEN_SECRET fall back to random keys)
 	os.Setenv("APP_ENV", "test")
+	os.Setenv("GO_ENV", "test")
 	logs.Init()
 
-	// 4. Run Tests
+	// 4. Keep verification tokens in memory so sign-up can be tested without AWS
+	verify.Store = verify.NewMemoryStore()
+	verify.ResetStore = verify.NewMemoryStore()
+
+	// 5. Run Tests
 	exitVal := m.Run()
 
-	// 5. Exit
+	// 6. Exit
 	os.Exit(exitVal)
 }
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"my-project/auth"
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/middleware"
+	"my-project/models"
+)
+
+func setupMFATestEnv() (*gin.Engine, *models.User) {
+	testDB := db.DB
+	testDB.Exec("DELETE FROM mfa_recovery_codes")
+	testDB.Exec("DELETE FROM login_attempts")
+	testDB.Exec("DELETE FROM users")
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	user := models.User{
+		Username: "mfa@example.com", Password: string(hashed), FirstName: "M", LastName: "FA", Verified: true,
+	}
+	testDB.Create(&user)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	v1 := r.Group("/v1/user")
+	v1.GET("/:userId", middleware.AuthenticateUser(), controllers.GetUser)
+	v1.POST("/:userId/mfa", middleware.AuthenticateUser(), controllers.EnrollMFA)
+	v1.POST("/:userId/mfa/confirm", middleware.AuthenticateUser(), controllers.ConfirmMFA)
+	v1.DELETE("/:userId/mfa", middleware.AuthenticateUser(), controllers.DeleteMFA)
+	r.POST("/v1/auth/token", controllers.CreateToken)
+
+	return r, &user
+}
+
+func TestMFA(t *testing.T) {
+	router, user := setupMFATestEnv()
+	userPath := fmt.Sprintf("/v1/user/%d", user.ID)
+
+	getUser := func(authorize func(*http.Request)) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", userPath, nil)
+		authorize(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	basic := func(code string) func(*http.Request) {
+		return func(req *http.Request) {
+			req.SetBasicAuth(user.Username, "password123")
+			if code != "" {
+				req.Header.Set("X-MFA-Code", code)
+			}
+		}
+	}
+	passwordGrant := func(code string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{
+			"grant_type": "password", "username": user.Username, "password": "password123", "mfa_code": code,
+		})
+		req, _ := http.NewRequest("POST", "/v1/auth/token", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 1. Enroll
+	req, _ := http.NewRequest("POST", userPath+"/mfa", nil)
+	req.SetBasicAuth(user.Username, "password123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	var enrollment map[string]string
+	json.Unmarshal(w.Body.Bytes(), &enrollment)
+	secret := enrollment["secret"]
+	assert.NotEmpty(t, secret)
+	assert.Contains(t, enrollment["otpauth_uri"], "otpauth://totp/")
+
+	t.Run("should not enforce MFA before confirmation", func(t *testing.T) {
+		assert.Equal(t, 200, getUser(basic("")).Code)
+	})
+
+	// 2. Confirm with the previous time step, so the current one is still usable below
+	code, _ := auth.TOTPCode(secret, time.Now().Add(-30*time.Second))
+	body, _ := json.Marshal(map[string]string{"code": code})
+	req, _ = http.NewRequest("POST", userPath+"/mfa/confirm", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(user.Username, "password123")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	var confirmation map[string][]string
+	json.Unmarshal(w.Body.Bytes(), &confirmation)
+	assert.Len(t, confirmation["recovery_codes"], 10)
+
+	t.Run("should refuse Basic Auth once enabled", func(t *testing.T) {
+		// Two requests in the same time step: a code could only be accepted once, so neither is
+		// checked and both get the same pointer to tokens
+		current, _ := auth.TOTPCode(secret, time.Now())
+		for i := 0; i < 2; i++ {
+			w := getUser(basic(current))
+			assert.Equal(t, 401, w.Code)
+			assert.Contains(t, w.Body.String(), auth.ErrMFABasicAuth.Error())
+			assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+		}
+		assert.Equal(t, 401, getUser(basic("")).Code)
+	})
+
+	t.Run("should require the code on the password grant", func(t *testing.T) {
+		w := passwordGrant("")
+		assert.Equal(t, 401, w.Code)
+		assert.Contains(t, w.Body.String(), auth.ErrMFARequired.Error())
+		assert.Equal(t, 401, passwordGrant("000000").Code)
+	})
+
+	t.Run("should accept a current code only once", func(t *testing.T) {
+		// The Basic requests above did not use up this step
+		current, _ := auth.TOTPCode(secret, time.Now())
+		w := passwordGrant(current)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, 401, passwordGrant(current).Code)
+
+		var tokens map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &tokens)
+		bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string)) }
+		assert.Equal(t, 200, getUser(bearer).Code)
+		assert.Equal(t, 200, getUser(bearer).Code)
+	})
+
+	t.Run("should accept a recovery code only once", func(t *testing.T) {
+		recovery := confirmation["recovery_codes"][0]
+		assert.Equal(t, 200, passwordGrant(recovery).Code)
+		assert.Equal(t, 401, passwordGrant(recovery).Code)
+	})
+
+	t.Run("should require a code to disable MFA", func(t *testing.T) {
+		w := passwordGrant(confirmation["recovery_codes"][1])
+		assert.Equal(t, 200, w.Code)
+		var tokens map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &tokens)
+
+		disable := func(body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("DELETE", userPath+"/mfa", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		// The access token alone is not enough
+		w = disable("")
+		assert.Equal(t, 401, w.Code)
+		assert.Contains(t, w.Body.String(), auth.ErrMFARequired.Error())
+		assert.Equal(t, 403, disable(`{"code": "000000"}`).Code)
+
+		var stillEnabled models.User
+		db.DB.First(&stillEnabled, user.ID)
+		assert.True(t, stillEnabled.MFAEnabled)
+
+		assert.Equal(t, 204, disable(`{"code": "`+confirmation["recovery_codes"][2]+`"}`).Code)
+
+		var disabled models.User
+		db.DB.First(&disabled, user.ID)
+		assert.False(t, disabled.MFAEnabled)
+	})
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA1 key "12345678901234567890" (8-digit codes truncated to 6)
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	code, err := auth.TOTPCode(secret, time.Unix(59, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	code, _ = auth.TOTPCode(secret, time.Unix(1111111109, 0))
+	assert.Equal(t, "081804", code)
+}
@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"my-project/auth"
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/models"
+	"my-project/oidc"
+)
+
+// stubIdP is a minimal OpenID provider: discovery, JWKS and a token endpoint that
+// checks the PKCE verifier and signs an ID token with whatever claims the test set.
+type stubIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string // key ID in the JWKS and token header, omitted when empty
+
+	// Set by the test before the callback: nonce/challenge come from the authorize redirect
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	idp := &stubIdP{key: key, kid: "test-key"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwk := map[string]string{
+			"kty": "RSA", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+		if idp.kid != "" {
+			jwk["kid"] = idp.kid
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{jwk}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if clientID != "webapp" || secret != "s3cret" || r.Form.Get("code") != "good-code" ||
+			oidc.CodeChallenge(r.Form.Get("code_verifier")) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims)
+		if idp.kid != "" {
+			token.Header["kid"] = idp.kid
+		}
+		signed, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "x", "token_type": "Bearer", "id_token": signed})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func setupOIDCTestEnv(t *testing.T) (*gin.Engine, *stubIdP) {
+	db.DB.Exec("DELETE FROM user_identities")
+	db.DB.Exec("DELETE FROM oidc_login_states")
+	db.DB.Exec("DELETE FROM users")
+
+	idp := newStubIdP(t)
+	oidc.Default = oidc.NewProvider(oidc.Config{
+		Issuer:       idp.server.URL,
+		ClientID:     "webapp",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost/v1/auth/oidc/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+	t.Cleanup(func() { oidc.Default = nil })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/v1/auth/oidc/login", controllers.OIDCLogin)
+	r.GET("/v1/auth/oidc/callback", controllers.OIDCCallback)
+	return r, idp
+}
+
+// oidcFlow drives the login and callback endpoints against the stub provider
+type oidcFlow struct {
+	t      *testing.T
+	router *gin.Engine
+	idp    *stubIdP
+}
+
+// login starts the flow and returns the authorize redirect parameters
+func (f *oidcFlow) login() url.Values {
+	req, _ := http.NewRequest("GET", "/v1/auth/oidc/login", nil)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	assert.Equal(f.t, 302, w.Code)
+
+	location, _ := url.Parse(w.Header().Get("Location"))
+	assert.Equal(f.t, f.idp.server.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+	assert.Equal(f.t, "S256", location.Query().Get("code_challenge_method"))
+	return location.Query()
+}
+
+func (f *oidcFlow) callback(params url.Values, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	f.idp.challenge = params.Get("code_challenge")
+	f.idp.claims = claims
+
+	query := url.Values{"code": {"good-code"}, "state": {params.Get("state")}}
+	req, _ := http.NewRequest("GET", "/v1/auth/oidc/callback?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func (f *oidcFlow) claimsFor(params url.Values, subject, email string, verified bool) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss": f.idp.server.URL, "aud": "webapp", "sub": subject,
+		"exp": time.Now().Add(time.Minute).Unix(), "iat": time.Now().Unix(),
+		"nonce": params.Get("nonce"), "email": email, "email_verified": verified,
+		"given_name": "Sso", "family_name": "User",
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	router, idp := setupOIDCTestEnv(t)
+	flow := &oidcFlow{t: t, router: router, idp: idp}
+	login, callback, claimsFor := flow.login, flow.callback, flow.claimsFor
+
+	t.Run("should provision a verified user and issue tokens", func(t *testing.T) {
+		params := login()
+		w := callback(params, claimsFor(params, "sub-1", "SSO@example.com", true))
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), "access_token")
+
+		var user models.User
+		assert.NoError(t, db.DB.Where("username = ?", "sso@example.com").First(&user).Error)
+		assert.True(t, user.Verified)
+	})
+
+	t.Run("should reuse the identity link on the next login", func(t *testing.T) {
+		params := login()
+		w := callback(params, claimsFor(params, "sub-1", "renamed@example.com", true))
+		assert.Equal(t, 200, w.Code)
+
+		var count int64
+		db.DB.Model(&models.User{}).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("should not reuse a state", func(t *testing.T) {
+		params := login()
+		assert.Equal(t, 200, callback(params, claimsFor(params, "sub-1", "sso@example.com", true)).Code)
+		assert.Equal(t, 400, callback(params, claimsFor(params, "sub-1", "sso@example.com", true)).Code)
+	})
+
+	t.Run("should reject a wrong nonce", func(t *testing.T) {
+		params := login()
+		claims := claimsFor(params, "sub-1", "sso@example.com", true)
+		claims["nonce"] = "replayed"
+		assert.Equal(t, 401, callback(params, claims).Code)
+	})
+
+	t.Run("should refuse to link an unverified email", func(t *testing.T) {
+		params := login()
+		assert.Equal(t, 403, callback(params, claimsFor(params, "sub-2", "other@example.com", false)).Code)
+	})
+	t.Run("should not let SSO bypass local MFA", func(t *testing.T) {
+		local := models.User{
+			Username: "mfa@example.com", Password: "x", FirstName: "M", LastName: "U", Verified: true,
+			MFAEnabled: true, MFASecret: "JBSWY3DPEHPK3PXP",
+		}
+		db.DB.Create(&local)
+
+		params := login()
+		w := callback(params, claimsFor(params, "sub-3", "mfa@example.com", true))
+		assert.Equal(t, 403, w.Code)
+		assert.NotContains(t, w.Body.String(), "access_token")
+
+		var count int64
+		db.DB.Model(&models.UserIdentity{}).Where("user_id = ?", local.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("should take over an unverified local account", func(t *testing.T) {
+		hashed, _ := bcrypt.GenerateFromPassword([]byte("attacker123"), bcrypt.DefaultCost)
+		squatted := models.User{Username: "victim@example.com", Password: string(hashed), FirstName: "S", LastName: "Q"}
+		db.DB.Create(&squatted)
+		pair, _ := auth.IssueTokenPair(&squatted)
+		_, apiKey, _ := auth.CreateAPIKey(&squatted, "squatter", []string{"product:read"})
+
+		params := login()
+		assert.Equal(t, 200, callback(params, claimsFor(params, "sub-5", "victim@example.com", true)).Code)
+
+		var user models.User
+		db.DB.First(&user, squatted.ID)
+		assert.True(t, user.Verified)
+
+		_, err := auth.CheckCredentials("victim@example.com", "attacker123", "", "203.0.113.9")
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+		_, _, err = auth.RotateRefreshToken(pair.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+		_, _, err = auth.AuthenticateAPIKey(apiKey)
+		assert.Error(t, err)
+	})
+
+	t.Run("should refuse a linked identity once the local account enables MFA", func(t *testing.T) {
+		linked := models.User{Username: "linked@example.com", Password: "x", FirstName: "L", LastName: "U", Verified: true}
+		db.DB.Create(&linked)
+
+		params := login()
+		assert.Equal(t, 200, callback(params, claimsFor(params, "sub-4", "linked@example.com", true)).Code)
+
+		db.DB.Model(&linked).Updates(map[string]interface{}{"mfa_enabled": true, "mfa_secret": "JBSWY3DPEHPK3PXP"})
+		params = login()
+		assert.Equal(t, 403, callback(params, claimsFor(params, "sub-4", "linked@example.com", true)).Code)
+	})
+
+	t.Run("should keep SSO logins for provisioned users with MFA", func(t *testing.T) {
+		db.DB.Model(&models.User{}).Where("username = ?", "sso@example.com").Updates(map[string]interface{}{"mfa_enabled": true, "mfa_secret": "JBSWY3DPEHPK3PXP"})
+		params := login()
+		assert.Equal(t, 200, callback(params, claimsFor(params, "sub-1", "sso@example.com", true)).Code)
+	})
+}
+
+func TestOIDCLoginWithoutKeyID(t *testing.T) {
+	router, idp := setupOIDCTestEnv(t)
+	idp.kid = ""
+	flow := &oidcFlow{t: t, router: router, idp: idp}
+
+	// The second login finds the key cached within the refresh interval
+	for i := 0; i < 2; i++ {
+		params := flow.login()
+		w := flow.callback(params, flow.claimsFor(params, "sub-1", "sso@example.com", true))
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), "access_token")
+	}
+}
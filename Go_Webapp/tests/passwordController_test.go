@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"my-project/auth"
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/middleware"
+	"my-project/models"
+	"my-project/policy"
+	"my-project/verify"
+)
+
+func setupPasswordTestEnv() (*gin.Engine, *models.User) {
+	testDB := db.DB
+	testDB.Exec("DELETE FROM users")
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("oldPassword"), bcrypt.DefaultCost)
+	user := models.User{
+		Username: "reset@example.com", Password: string(hashed), FirstName: "Reset", LastName: "User", Verified: true,
+	}
+	testDB.Create(&user)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	v1 := r.Group("/v1/user")
+	v1.POST("/password/forgot", controllers.ForgotPassword)
+	v1.POST("/password/reset", controllers.ResetPassword)
+	v1.GET("/:userId", middleware.AuthenticateUser(), controllers.GetUser)
+
+	return r, &user
+}
+
+func TestPasswordController(t *testing.T) {
+
+	t.Run("POST /v1/user/password/forgot", func(t *testing.T) {
+		router, user := setupPasswordTestEnv()
+
+		t.Run("should return 202 and issue a reset token", func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"username": user.Username})
+			req, _ := http.NewRequest("POST", "/v1/user/password/forgot", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 202, w.Code)
+
+			_, err := verify.ResetStore.Lookup(context.Background(), user.Username)
+			assert.NoError(t, err)
+		})
+
+		t.Run("should return 202 for an unknown address", func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"username": "nobody@example.com"})
+			req, _ := http.NewRequest("POST", "/v1/user/password/forgot", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 202, w.Code)
+		})
+	})
+
+	t.Run("POST /v1/user/password/reset", func(t *testing.T) {
+		router, user := setupPasswordTestEnv()
+		token, _ := verify.ResetStore.Issue(context.Background(), user.Username, time.Hour)
+		_, rawKey, _ := auth.CreateAPIKey(user, "ci", []string{string(policy.ProductWrite)})
+
+		reset := func(token string) int {
+			body, _ := json.Marshal(map[string]string{
+				"username": user.Username, "token": token, "password": "newPassw0rd",
+			})
+			req, _ := http.NewRequest("POST", "/v1/user/password/reset", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		t.Run("should return 400 for a wrong token", func(t *testing.T) {
+			assert.Equal(t, 400, reset("wrong"))
+		})
+
+		t.Run("should set the new password and return 204", func(t *testing.T) {
+			assert.Equal(t, 204, reset(token))
+
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/user/%d", user.ID), nil)
+			req.SetBasicAuth(user.Username, "newPassw0rd")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code)
+		})
+
+		t.Run("should revoke the user's api keys", func(t *testing.T) {
+			assert.NotEmpty(t, rawKey)
+			_, _, err := auth.AuthenticateAPIKey(rawKey)
+			assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+		})
+
+		t.Run("should not accept the same token twice", func(t *testing.T) {
+			assert.Equal(t, 400, reset(token))
+		})
+
+		t.Run("should lift a lockout on the username", func(t *testing.T) {
+			t.Setenv("LOCKOUT_THRESHOLD", "3")
+			db.DB.Exec("DELETE FROM login_attempts")
+			t.Cleanup(func() { db.DB.Exec("DELETE FROM login_attempts") })
+
+			get := func(password string) int {
+				req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/user/%d", user.ID), nil)
+				req.SetBasicAuth(user.Username, password)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				return w.Code
+			}
+			for i := 0; i < 3; i++ {
+				get("wrong")
+			}
+			assert.NotEqual(t, 200, get("newPassw0rd"))
+
+			fresh, _ := verify.ResetStore.Issue(context.Background(), user.Username, time.Hour)
+			assert.Equal(t, 204, reset(fresh))
+			assert.Equal(t, 200, get("newPassw0rd"))
+		})
+	})
+}
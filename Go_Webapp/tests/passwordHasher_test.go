@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"my-project/auth"
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/middleware"
+	"my-project/models"
+)
+
+func TestPasswordHasher(t *testing.T) {
+
+	t.Run("argon2id", func(t *testing.T) {
+		encoded, err := auth.HashPassword("correct horse")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(encoded, "$argon2id$"))
+
+		ok, needsRehash, err := auth.VerifyPassword(encoded, "correct horse")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, needsRehash)
+
+		ok, _, _ = auth.VerifyPassword(encoded, "wrong horse")
+		assert.False(t, ok)
+	})
+
+	t.Run("should default to the OWASP baseline parameters", func(t *testing.T) {
+		encoded, _ := auth.HashPassword("correct horse")
+		assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=19456,t=2,p=1$"))
+	})
+
+	t.Run("should flag argon2id hashes with outdated parameters", func(t *testing.T) {
+		// Hash under the old setting; t.Setenv restores the default when the subtest ends
+		var encoded string
+		t.Run("hash with one iteration", func(t *testing.T) {
+			t.Setenv("ARGON2_ITERATIONS", "1")
+			encoded, _ = auth.HashPassword("correct horse")
+		})
+
+		ok, needsRehash, _ := auth.VerifyPassword(encoded, "correct horse")
+		assert.True(t, ok)
+		assert.True(t, needsRehash)
+	})
+
+	t.Run("should still verify legacy bcrypt hashes and flag them", func(t *testing.T) {
+		legacy, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+
+		ok, needsRehash, err := auth.VerifyPassword(string(legacy), "correct horse")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, needsRehash)
+	})
+
+	t.Run("should reject an unknown hash format", func(t *testing.T) {
+		_, _, err := auth.VerifyPassword("plaintext", "plaintext")
+		assert.ErrorIs(t, err, auth.ErrUnknownHashFormat)
+	})
+
+	t.Run("should rehash a bcrypt password on successful login", func(t *testing.T) {
+		db.DB.Exec("DELETE FROM login_attempts")
+		db.DB.Exec("DELETE FROM users")
+
+		legacy, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		user := models.User{Username: "rehash@example.com", Password: string(legacy), FirstName: "R", LastName: "H", Verified: true}
+		db.DB.Create(&user)
+
+		gin.SetMode(gin.TestMode)
+		r := gin.Default()
+		r.GET("/v1/user/:userId", middleware.AuthenticateUser(), controllers.GetUser)
+
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/user/%d", user.ID), nil)
+		req.SetBasicAuth(user.Username, "password123")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+
+		var updated models.User
+		db.DB.First(&updated, user.ID)
+		assert.True(t, strings.HasPrefix(updated.Password, "$argon2id$"))
+	})
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/middleware"
+	"my-project/models"
+	"my-project/policy"
+)
+
+func setupPolicyTestEnv() (*gin.Engine, map[string]*models.User) {
+	testDB := db.DB
+	testDB.Exec("DELETE FROM image")
+	testDB.Exec("DELETE FROM product")
+	testDB.Exec("DELETE FROM users")
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	users := map[string]*models.User{}
+	for _, role := range []string{policy.RoleUser, policy.RoleAdmin, policy.RoleAuditor} {
+		user := models.User{
+			Username: role + "@example.com", Password: string(hashed), FirstName: "Role", LastName: "User",
+			Verified: true, Role: role,
+		}
+		testDB.Create(&user)
+		users[role] = &user
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	product := r.Group("/v1/product")
+	product.POST("/", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ProductWrite), controllers.CreateProduct)
+	product.DELETE("/:productId", middleware.AuthenticateUser(), middleware.RequirePermission(policy.ProductWrite), controllers.DeleteProduct)
+
+	r.GET("/v1/user/:userId", middleware.AuthenticateUser(), controllers.GetUser)
+
+	admin := r.Group("/v1/admin")
+	admin.Use(middleware.AuthenticateUser(), middleware.RequirePermission(policy.UserAdmin))
+	admin.PUT("/users/:userId/role", controllers.UpdateUserRole)
+
+	return r, users
+}
+
+func TestRoleBasedAccess(t *testing.T) {
+	router, users := setupPolicyTestEnv()
+
+	do := func(method, path string, as *models.User, body interface{}) *httptest.ResponseRecorder {
+		var reader *bytes.Buffer
+		if body != nil {
+			payload, _ := json.Marshal(body)
+			reader = bytes.NewBuffer(payload)
+		} else {
+			reader = bytes.NewBuffer(nil)
+		}
+		req, _ := http.NewRequest(method, path, reader)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.SetBasicAuth(as.Username, "password123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	product := models.Product{
+		Name: "Owned", Description: "Desc", Sku: "RBAC-001", Manufacturer: "M", Quantity: 1,
+		OwnerUserID: users[policy.RoleUser].ID,
+	}
+	db.DB.Create(&product)
+
+	t.Run("auditor cannot create products", func(t *testing.T) {
+		w := do("POST", "/v1/product/", users[policy.RoleAuditor], map[string]interface{}{
+			"name": "X", "description": "D", "sku": "RBAC-002", "manufacturer": "M", "quantity": 1,
+		})
+		assert.Equal(t, 403, w.Code)
+	})
+
+	t.Run("auditor can read another user's profile", func(t *testing.T) {
+		w := do("GET", fmt.Sprintf("/v1/user/%d", users[policy.RoleUser].ID), users[policy.RoleAuditor], nil)
+		assert.Equal(t, 200, w.Code)
+	})
+
+	t.Run("regular user cannot read another user's profile", func(t *testing.T) {
+		w := do("GET", fmt.Sprintf("/v1/user/%d", users[policy.RoleAdmin].ID), users[policy.RoleUser], nil)
+		assert.Equal(t, 403, w.Code)
+	})
+
+	t.Run("regular user cannot change roles", func(t *testing.T) {
+		w := do("PUT", fmt.Sprintf("/v1/admin/users/%d/role", users[policy.RoleUser].ID), users[policy.RoleUser], map[string]string{"role": policy.RoleAdmin})
+		assert.Equal(t, 403, w.Code)
+	})
+
+	t.Run("admin can change roles", func(t *testing.T) {
+		w := do("PUT", fmt.Sprintf("/v1/admin/users/%d/role", users[policy.RoleAuditor].ID), users[policy.RoleAdmin], map[string]string{"role": policy.RoleUser})
+		assert.Equal(t, 204, w.Code)
+
+		w = do("PUT", fmt.Sprintf("/v1/admin/users/%d/role", users[policy.RoleAuditor].ID), users[policy.RoleAdmin], map[string]string{"role": "root"})
+		assert.Equal(t, 400, w.Code)
+	})
+
+	t.Run("admin can delete another user's product", func(t *testing.T) {
+		t.Setenv("REQUIRE_IF_MATCH", "false")
+		w := do("DELETE", fmt.Sprintf("/v1/product/%d", product.ID), users[policy.RoleAdmin], nil)
+		assert.Equal(t, 204, w.Code)
+	})
+}
+
+func TestBootstrapAdmins(t *testing.T) {
+	_, users := setupPolicyTestEnv()
+
+	squatter := models.User{
+		Username: "operator@example.com", Password: "x", FirstName: "Not", LastName: "Verified", Role: policy.RoleUser,
+	}
+	db.DB.Create(&squatter)
+
+	t.Setenv("ADMIN_USERNAMES", " USER@example.com ,auditor@example.com,nobody@example.com,operator@example.com")
+
+	t.Run("should promote listed users case-insensitively", func(t *testing.T) {
+		promoted, err := policy.BootstrapAdmins(db.DB)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), promoted)
+
+		for _, role := range []string{policy.RoleUser, policy.RoleAuditor} {
+			var user models.User
+			db.DB.First(&user, users[role].ID)
+			assert.Equal(t, policy.RoleAdmin, user.Role)
+		}
+	})
+
+	t.Run("should not promote unverified accounts", func(t *testing.T) {
+		var user models.User
+		db.DB.First(&user, squatter.ID)
+		assert.Equal(t, policy.RoleUser, user.Role)
+	})
+
+	t.Run("should be a no-op once applied", func(t *testing.T) {
+		promoted, err := policy.BootstrapAdmins(db.DB)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), promoted)
+	})
+
+	t.Run("should not demote admins left off the list", func(t *testing.T) {
+		var admin models.User
+		db.DB.First(&admin, users[policy.RoleAdmin].ID)
+		assert.Equal(t, policy.RoleAdmin, admin.Role)
+	})
+}
@@ -36,6 +36,7 @@ func setupProductTestEnv() (*gin.Engine, *models.User, *gorm.DB) {
 		Password:  string(hashedPwd), // Save HASHED password in DB
 		FirstName: "Test",
 		LastName:  "User",
+		Verified:  true,
 	}
 	testDB.Create(&user)
 
@@ -112,6 +113,7 @@ func TestProductController(t *testing.T) {
 
 		t.Run("should delete and return 204", func(t *testing.T) {
 			req, _ := http.NewRequest("DELETE", fmt.Sprintf("/v1/product/%d", product.ID), nil)
+			req.Header.Set("If-Match", `"1"`)
 
 			// USE BASIC AUTH
 			req.SetBasicAuth(user.Username, user.Password)
@@ -123,3 +125,190 @@ func TestProductController(t *testing.T) {
 		})
 	})
 }
+
+func TestProductListing(t *testing.T) {
+	router, user, db := setupProductTestEnv()
+	db.Exec("DELETE FROM product")
+
+	for i, manufacturer := range []string{"Acme", "Acme", "Globex", "Acme", "Initech"} {
+		db.Create(&models.Product{
+			Name: fmt.Sprintf("Item %d", i), Description: "Desc", Sku: fmt.Sprintf("LIST-%d", i),
+			Manufacturer: manufacturer, Quantity: i * 10, OwnerUserID: user.ID,
+		})
+	}
+
+	list := func(query string) ([]models.Product, *httptest.ResponseRecorder) {
+		req, _ := http.NewRequest("GET", "/v1/product/"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var products []models.Product
+		json.Unmarshal(w.Body.Bytes(), &products)
+		return products, w
+	}
+
+	t.Run("should follow the next cursor until the last page", func(t *testing.T) {
+		var skus []string
+		query := "?limit=2&sort=-quantity"
+		for pages := 0; query != "" && pages < 5; pages++ {
+			products, w := list(query)
+			assert.Equal(t, 200, w.Code)
+			for _, product := range products {
+				skus = append(skus, product.Sku)
+			}
+
+			query = ""
+			if next := w.Header().Get("X-Next-Cursor"); next != "" {
+				assert.Contains(t, w.Header().Get("Link"), `rel="next"`)
+				query = "?limit=2&sort=-quantity&cursor=" + next
+			}
+		}
+		assert.Equal(t, []string{"LIST-4", "LIST-3", "LIST-2", "LIST-1", "LIST-0"}, skus)
+	})
+
+	t.Run("should filter by manufacturer and quantity range", func(t *testing.T) {
+		products, w := list("?manufacturer=Acme&quantity_min=10&quantity_max=30")
+		assert.Equal(t, 200, w.Code)
+		assert.Len(t, products, 2)
+		assert.Empty(t, w.Header().Get("Link"))
+	})
+
+	t.Run("should return 400 for unknown parameters, sorts and cursors", func(t *testing.T) {
+		for _, query := range []string{"?color=red", "?sort=password", "?limit=1000", "?cursor=garbage", "?sku=a&sku=b"} {
+			_, w := list(query)
+			assert.Equal(t, 400, w.Code, query)
+		}
+	})
+
+	t.Run("should reject a cursor issued for another sort order", func(t *testing.T) {
+		_, w := list("?limit=1&sort=name")
+		_, w = list("?sort=quantity&cursor=" + w.Header().Get("X-Next-Cursor"))
+		assert.Equal(t, 400, w.Code)
+	})
+}
+
+func TestProductConditionalRequests(t *testing.T) {
+	router, user, db := setupProductTestEnv()
+
+	product := models.Product{
+		Name: "Versioned", Description: "Desc", Sku: "ETAG-1", Manufacturer: "Acme", Quantity: 1, OwnerUserID: user.ID,
+	}
+	db.Create(&product)
+
+	send := func(method, ifMatch string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, fmt.Sprintf("/v1/product/%d", product.ID), &buf)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		req.SetBasicAuth(user.Username, user.Password)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should return an ETag and 304 for a matching If-None-Match", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/product/%d", product.ID), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+		req.Header.Set("If-None-Match", `"1"`)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 304, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("should bump the version on a matching update", func(t *testing.T) {
+		w := send("PATCH", `"1"`, map[string]interface{}{"quantity": 5})
+		assert.Equal(t, 204, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("should return 412 for a stale If-Match", func(t *testing.T) {
+		w := send("PATCH", `"1"`, map[string]interface{}{"quantity": 7})
+		assert.Equal(t, 412, w.Code)
+
+		assert.Equal(t, 412, send("DELETE", `"1"`, nil).Code)
+
+		var stored models.Product
+		db.First(&stored, product.ID)
+		assert.Equal(t, 5, stored.Quantity)
+		assert.Equal(t, 2, stored.Version)
+	})
+
+	t.Run("should return 428 without If-Match", func(t *testing.T) {
+		assert.Equal(t, 428, send("PATCH", "", map[string]interface{}{"quantity": 7}).Code)
+		assert.Equal(t, 428, send("DELETE", "", nil).Code)
+	})
+
+	t.Run("should allow writes without If-Match when REQUIRE_IF_MATCH=false", func(t *testing.T) {
+		t.Setenv("REQUIRE_IF_MATCH", "false")
+		assert.Equal(t, 204, send("PATCH", "", map[string]interface{}{"quantity": 7}).Code)
+		assert.Equal(t, 204, send("DELETE", "", nil).Code)
+	})
+}
+
+func TestProductPatchFormats(t *testing.T) {
+	router, user, db := setupProductTestEnv()
+
+	product := models.Product{
+		Name: "Patchable", Description: "Desc", Sku: "PATCH-1", Manufacturer: "Acme", Quantity: 10, OwnerUserID: user.ID,
+	}
+	db.Create(&product)
+
+	patch := func(contentType, body string) int {
+		req, _ := http.NewRequest("PATCH", fmt.Sprintf("/v1/product/%d", product.ID), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("If-Match", "*")
+		req.SetBasicAuth(user.Username, user.Password)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	stored := func() models.Product {
+		var p models.Product
+		db.First(&p, product.ID)
+		return p
+	}
+
+	t.Run("should apply a merge patch", func(t *testing.T) {
+		assert.Equal(t, 204, patch("application/merge-patch+json", `{"name":"Merged","quantity":20}`))
+		assert.Equal(t, "Merged", stored().Name)
+		assert.Equal(t, 20, stored().Quantity)
+	})
+
+	t.Run("should apply a JSON patch", func(t *testing.T) {
+		body := `[{"op":"test","path":"/quantity","value":20},{"op":"replace","path":"/sku","value":"PATCH-2"}]`
+		assert.Equal(t, 204, patch("application/json-patch+json", body))
+		assert.Equal(t, "PATCH-2", stored().Sku)
+	})
+
+	t.Run("should return 409 when a test operation fails", func(t *testing.T) {
+		body := `[{"op":"test","path":"/quantity","value":99},{"op":"replace","path":"/name","value":"Nope"}]`
+		assert.Equal(t, 409, patch("application/json-patch+json", body))
+		assert.Equal(t, "Merged", stored().Name)
+	})
+
+	t.Run("should validate the patched product like a PUT", func(t *testing.T) {
+		assert.Equal(t, 400, patch("application/merge-patch+json", `{"name":""}`))
+		assert.Equal(t, 400, patch("application/merge-patch+json", `{"sku":5}`))
+		assert.Equal(t, 400, patch("application/merge-patch+json", `{"description":null}`))
+		assert.Equal(t, 400, patch("application/merge-patch+json", `{"owner_user_id":1}`))
+		assert.Equal(t, 400, patch("application/json-patch+json", `[{"op":"remove","path":"/manufacturer"}]`))
+		assert.Equal(t, "PATCH-2", stored().Sku)
+	})
+
+	t.Run("should return 415 for other media types", func(t *testing.T) {
+		assert.Equal(t, 415, patch("text/plain", `{"name":"x"}`))
+	})
+}
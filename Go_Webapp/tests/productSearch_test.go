@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"my-project/controllers"
+	"my-project/db"
+	"my-project/models"
+)
+
+func setupProductSearchTestEnv() *gin.Engine {
+	testDB := db.DB
+	testDB.Exec("DELETE FROM image")
+	testDB.Exec("DELETE FROM product")
+
+	for _, product := range []models.Product{
+		{Name: "Laptop Stand", Description: "Aluminium stand for any notebook", Sku: "S-1", Manufacturer: "Desk Co", Quantity: 1},
+		{Name: "USB Hub", Description: "Four ports, works with every laptop", Sku: "S-2", Manufacturer: "Cables Inc", Quantity: 1},
+		{Name: "Office Chair", Description: "Ergonomic", Sku: "S-3", Manufacturer: "Desk Co", Quantity: 1},
+		{Name: "<script>alert(1)</script> Lamp", Description: "<img src=x onerror=alert(1)> bright lamp", Sku: "S-4", Manufacturer: "Light & Co", Quantity: 1},
+	} {
+		testDB.Create(&product)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/v1/product/search", controllers.SearchProduct)
+	return r
+}
+
+func TestProductSearch(t *testing.T) {
+	router := setupProductSearchTestEnv()
+
+	search := func(query url.Values) ([]controllers.ProductSearchResult, int) {
+		req, _ := http.NewRequest("GET", "/v1/product/search?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var results []controllers.ProductSearchResult
+		json.Unmarshal(w.Body.Bytes(), &results)
+		return results, w.Code
+	}
+
+	t.Run("should match word prefixes and rank name matches first", func(t *testing.T) {
+		results, code := search(url.Values{"q": {"lapt"}})
+		assert.Equal(t, 200, code)
+		assert.Len(t, results, 2)
+		assert.Equal(t, "S-1", results[0].Sku)
+		assert.Contains(t, results[0].NameHighlight, "<mark>Laptop</mark>")
+		assert.Contains(t, results[1].Snippet, "<mark>laptop</mark>")
+	})
+
+	t.Run("should escape the product text around the highlights", func(t *testing.T) {
+		results, code := search(url.Values{"q": {"lamp"}})
+		assert.Equal(t, 200, code)
+		if assert.Len(t, results, 1) {
+			assert.Contains(t, results[0].NameHighlight, "<mark>Lamp</mark>")
+			assert.Contains(t, results[0].NameHighlight, "&lt;script&gt;")
+			assert.NotContains(t, results[0].NameHighlight, "<script")
+			assert.Contains(t, results[0].Snippet, "<mark>lamp</mark>")
+			assert.NotContains(t, results[0].Snippet, "<img")
+		}
+	})
+
+	t.Run("should require every word", func(t *testing.T) {
+		results, _ := search(url.Values{"q": {"desk chair"}})
+		assert.Len(t, results, 1)
+		assert.Equal(t, "S-3", results[0].Sku)
+	})
+
+	t.Run("should ignore tsquery syntax in the input", func(t *testing.T) {
+		_, code := search(url.Values{"q": {"chair & !(| :*"}})
+		assert.Equal(t, 200, code)
+	})
+
+	t.Run("should return 400 without a query or with unknown parameters", func(t *testing.T) {
+		_, code := search(url.Values{"q": {"  "}})
+		assert.Equal(t, 400, code)
+		_, code = search(url.Values{"q": {"chair"}, "sort": {"name"}})
+		assert.Equal(t, 400, code)
+	})
+}
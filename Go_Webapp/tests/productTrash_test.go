@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/clause"
+
+	"my-project/controllers"
+	"my-project/jobs"
+	"my-project/middleware"
+	"my-project/models"
+	"my-project/storage"
+)
+
+func TestProductTrash(t *testing.T) {
+	_, user, product, database := setupImageTestEnv(t)
+	database.Exec("DELETE FROM storage_deletions")
+	ctx := context.Background()
+
+	keys := []string{}
+	images := []models.Image{}
+	for i := 0; i < 2; i++ {
+		key := fmt.Sprintf("%d/%d/trash-%d.png", user.ID, product.ID, i)
+		storage.Store.Put(ctx, key, bytes.NewReader([]byte("bytes")), "image/png")
+		image := models.Image{ProductID: product.ID, FileName: fmt.Sprintf("trash-%d.png", i), S3BucketPath: key}
+		database.Create(&image)
+		keys = append(keys, key)
+		images = append(images, image)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	v1 := router.Group("/v1/product", middleware.AuthenticateUser())
+	v1.GET("/trash", controllers.GetProductTrash)
+	v1.DELETE("/:productId", controllers.DeleteProduct)
+	v1.POST("/:productId/restore", controllers.RestoreProduct)
+	v1.DELETE("/:productId/image/:imageId", controllers.DeleteImage)
+	v1.POST("/:productId/image/:imageId/restore", controllers.RestoreImage)
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("If-Match", "*")
+		req.SetBasicAuth(user.Username, user.Password)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	liveImages := func() int64 {
+		var count int64
+		database.Model(&models.Image{}).Where("product_id = ?", product.ID).Count(&count)
+		return count
+	}
+
+	t.Run("should list a deleted product in the trash", func(t *testing.T) {
+		assert.Equal(t, 204, send("DELETE", fmt.Sprintf("/v1/product/%d/image/%d", product.ID, images[0].ImageID)).Code)
+		assert.Equal(t, 204, send("DELETE", fmt.Sprintf("/v1/product/%d", product.ID)).Code)
+		assert.Equal(t, int64(0), liveImages())
+
+		w := send("GET", "/v1/product/trash")
+		assert.Equal(t, 200, w.Code)
+
+		var trashed []controllers.TrashedProduct
+		json.Unmarshal(w.Body.Bytes(), &trashed)
+		if assert.Len(t, trashed, 1) {
+			assert.Equal(t, product.ID, trashed[0].ID)
+			assert.WithinDuration(t, trashed[0].DeletedAt.Add(jobs.TrashRetention()), trashed[0].PurgeAt, time.Second)
+		}
+	})
+
+	t.Run("should restore the product with the images deleted along with it", func(t *testing.T) {
+		assert.Equal(t, 200, send("POST", fmt.Sprintf("/v1/product/%d/restore", product.ID)).Code)
+		assert.Equal(t, 404, send("POST", fmt.Sprintf("/v1/product/%d/restore", product.ID)).Code)
+		assert.Equal(t, int64(1), liveImages())
+
+		assert.Equal(t, 200, send("POST", fmt.Sprintf("/v1/product/%d/image/%d/restore", product.ID, images[0].ImageID)).Code)
+		assert.Equal(t, int64(2), liveImages())
+	})
+
+	t.Run("should keep trashed rows and objects until the retention has passed", func(t *testing.T) {
+		assert.Equal(t, 204, send("DELETE", fmt.Sprintf("/v1/product/%d", product.ID)).Code)
+
+		assert.NoError(t, jobs.ProcessTrash(ctx))
+		var remaining int64
+		database.Unscoped().Model(&models.Product{}).Where("id = ?", product.ID).Count(&remaining)
+		assert.Equal(t, int64(1), remaining)
+
+		t.Setenv("TRASH_RETENTION", "0s")
+		assert.NoError(t, jobs.ProcessTrash(ctx))
+
+		database.Unscoped().Model(&models.Product{}).Where("id = ?", product.ID).Count(&remaining)
+		assert.Equal(t, int64(0), remaining)
+		database.Unscoped().Model(&models.Image{}).Where("product_id = ?", product.ID).Count(&remaining)
+		assert.Equal(t, int64(0), remaining)
+	})
+
+	t.Run("should delete purged objects through the outbox, retrying failures", func(t *testing.T) {
+		local := storage.Store
+		storage.Store = &flakyStore{ObjectStore: local, failOnce: map[string]bool{keys[1]: true}}
+		t.Cleanup(func() { storage.Store = local })
+
+		assert.NoError(t, jobs.ProcessStorageDeletions(ctx))
+		_, err := storage.Store.Head(ctx, keys[0])
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = storage.Store.Head(ctx, keys[1])
+		assert.NoError(t, err)
+
+		var pending models.StorageDeletion
+		assert.NoError(t, database.Where("object_key = ?", keys[1]).First(&pending).Error)
+		assert.Equal(t, 1, pending.Attempts)
+		assert.NotEmpty(t, pending.LastError)
+
+		// Once the backoff has passed the next run finishes the job
+		database.Model(&pending).Update("next_attempt_at", time.Now().Add(-time.Second))
+		assert.NoError(t, jobs.ProcessStorageDeletions(ctx))
+		_, err = storage.Store.Head(ctx, keys[1])
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+
+		var count int64
+		database.Model(&models.StorageDeletion{}).Where("object_key IN ?", keys).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("should lease outbox entries instead of locking them during storage calls", func(t *testing.T) {
+		key := fmt.Sprintf("%d/%d/leased.png", user.ID, product.ID)
+		storage.Store.Put(ctx, key, bytes.NewReader([]byte("bytes")), "image/png")
+		assert.NoError(t, jobs.EnqueueStorageDeletions(database, []string{key}))
+
+		local := storage.Store
+		observed := false
+		storage.Store = &observedStore{ObjectStore: local, onDelete: func(key string) {
+			// NOWAIT fails right away if the job still held the row lock
+			var entry models.StorageDeletion
+			err := database.Clauses(clause.Locking{Strength: "UPDATE", Options: "NOWAIT"}).Where("object_key = ?", key).First(&entry).Error
+			assert.NoError(t, err)
+			assert.True(t, entry.NextAttemptAt.After(time.Now()), "entry should be leased past now")
+			observed = true
+		}}
+		t.Cleanup(func() { storage.Store = local })
+
+		assert.NoError(t, jobs.ProcessStorageDeletions(ctx))
+		assert.True(t, observed)
+
+		var count int64
+		database.Model(&models.StorageDeletion{}).Where("object_key = ?", key).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
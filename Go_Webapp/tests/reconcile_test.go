@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"my-project/models"
+	"my-project/reconcile"
+	"my-project/storage"
+)
+
+// listedStore calls afterList once List has returned, to change things behind the listing's back
+type listedStore struct {
+	storage.ObjectStore
+	afterList func()
+}
+
+func (s *listedStore) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	objects, err := s.ObjectStore.List(ctx, prefix)
+	if s.afterList != nil {
+		s.afterList()
+	}
+	return objects, err
+}
+
+func TestReconcile(t *testing.T) {
+	_, user, product, database := setupImageTestEnv(t)
+	database.Exec("DELETE FROM storage_deletions")
+	database.Exec("DELETE FROM account_deletions")
+	ctx := context.Background()
+
+	prefix := fmt.Sprintf("%d/", user.ID)
+	put := func(key string) {
+		storage.Store.Put(ctx, key, strings.NewReader("img"), "image/png")
+	}
+
+	kept := models.Image{ProductID: product.ID, FileName: "kept.png", S3BucketPath: prefix + "kept.png"}
+	put(kept.S3BucketPath)
+	database.Create(&kept)
+
+	dangling := models.Image{ProductID: product.ID, FileName: "gone.png", S3BucketPath: prefix + "gone.png"}
+	database.Create(&dangling)
+
+	orphanKey := prefix + "orphan.png"
+	put(orphanKey)
+	put(prefix + "exports/archive.zip")
+
+	t.Run("should report orphans and dangling rows without changing anything", func(t *testing.T) {
+		report, err := reconcile.Run(ctx, database, storage.Store, reconcile.Options{Prefix: prefix})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, report.ObjectsScanned)
+		assert.Equal(t, 2, report.RowsScanned)
+
+		if assert.Len(t, report.Orphans, 1) {
+			assert.Equal(t, orphanKey, report.Orphans[0].Key)
+			assert.False(t, report.Orphans[0].Deleted)
+		}
+		if assert.Len(t, report.Dangling, 1) {
+			assert.Equal(t, dangling.ImageID, report.Dangling[0].ImageID)
+		}
+
+		_, err = storage.Store.Head(ctx, orphanKey)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should skip objects younger than the minimum age", func(t *testing.T) {
+		report, err := reconcile.Run(ctx, database, storage.Store, reconcile.Options{Prefix: prefix, MinAge: time.Hour})
+		assert.NoError(t, err)
+		assert.Empty(t, report.Orphans)
+	})
+
+	t.Run("should delete orphans and flag dangling rows when repairing", func(t *testing.T) {
+		report, err := reconcile.Run(ctx, database, storage.Store, reconcile.Options{Prefix: prefix, Repair: true})
+		assert.NoError(t, err)
+		assert.True(t, report.Orphans[0].Deleted)
+		assert.True(t, report.Dangling[0].Flagged)
+
+		_, err = storage.Store.Head(ctx, orphanKey)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+
+		var flagged models.Image
+		database.First(&flagged, dangling.ImageID)
+		assert.NotNil(t, flagged.MissingAt)
+	})
+
+	t.Run("should clear the flag once the object is back", func(t *testing.T) {
+		put(dangling.S3BucketPath)
+
+		report, err := reconcile.Run(ctx, database, storage.Store, reconcile.Options{Prefix: prefix, Repair: true})
+		assert.NoError(t, err)
+		assert.Empty(t, report.Orphans)
+		assert.Empty(t, report.Dangling)
+		assert.Equal(t, []uint{dangling.ImageID}, report.Recovered)
+
+		var recovered models.Image
+		database.First(&recovered, dangling.ImageID)
+		assert.Nil(t, recovered.MissingAt)
+	})
+
+	t.Run("should not flag a row created after the listing", func(t *testing.T) {
+		// Same order as CreateImage: upload, then insert, both after List has run
+		late := models.Image{ProductID: product.ID, FileName: "late.png", S3BucketPath: prefix + "late.png"}
+		store := &listedStore{ObjectStore: storage.Store, afterList: func() {
+			put(late.S3BucketPath)
+			database.Create(&late)
+		}}
+
+		report, err := reconcile.Run(ctx, database, store, reconcile.Options{Prefix: prefix, Repair: true})
+		assert.NoError(t, err)
+		assert.Empty(t, report.Dangling)
+
+		var created models.Image
+		database.First(&created, late.ImageID)
+		assert.Nil(t, created.MissingAt)
+	})
+}
+
+func TestReconcileCommandOutput(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "reconcile")
+	build := exec.Command("go", "build", "-o", binary, "./cmd/reconcile")
+	build.Dir = ".."
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build the reconcile command: %v\n%s", err, out)
+	}
+
+	// Outside GO_ENV=test the server logs every SQL statement, which the command must keep off stdout
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GO_ENV=") && !strings.HasPrefix(kv, "APP_ENV=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, "STORAGE_BACKEND=local", "LOCAL_STORAGE_DIR="+filepath.Join(dir, "objects"))
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(binary, "-prefix", "reconcile-command-test/")
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 2) {
+		t.Fatalf("Reconcile command failed: %v\n%s", err, stderr.String())
+	}
+
+	var report reconcile.Report
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &report), "stdout: %s", stdout.String())
+	assert.Equal(t, "reconcile-command-test/", report.Prefix)
+}
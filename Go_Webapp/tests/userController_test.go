@@ -2,14 +2,20 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"my-project/auth"
 	"my-project/controllers"
 	"my-project/db"
 	"my-project/middleware"
 	"my-project/models"
+	"my-project/policy"
+	"my-project/verify"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,6 +36,8 @@ func setupUserTestEnv() (*gin.Engine, *gorm.DB) {
 
 	// Public
 	v1.POST("/", controllers.CreateUser)
+	v1.GET("/verifyEmail", controllers.VerifyEmail)
+	v1.POST("/verifyEmail/resend", controllers.ResendVerification)
 
 	// Protected
 	protected := v1.Group("/")
@@ -69,6 +77,108 @@ func TestUserController(t *testing.T) {
 			db.Where("username = ?", data["username"]).First(&u)
 			assert.NotEqual(t, "Password123", u.Password)
 		})
+
+		t.Run("should apply the shared password length limits", func(t *testing.T) {
+			for password, code := range map[string]int{
+				strings.Repeat("p", auth.MinPasswordLength-1): 400,
+				strings.Repeat("p", 20):                       201,
+				strings.Repeat("p", auth.MaxPasswordLength):   201,
+				strings.Repeat("p", auth.MaxPasswordLength+1): 400,
+			} {
+				body, _ := json.Marshal(map[string]string{
+					"username": fmt.Sprintf("length%d-%d@example.com", len(password), time.Now().UnixNano()),
+					"password": password, "first_name": "F", "last_name": "L",
+				})
+				req, _ := http.NewRequest("POST", "/v1/user/", bytes.NewBuffer(body))
+				req.Header.Set("Content-Type", "application/json")
+
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, code, w.Code, "password of %d characters", len(password))
+			}
+		})
+	})
+
+	// --- Sign-up and Verify Tests ---
+	t.Run("GET /v1/user/verifyEmail", func(t *testing.T) {
+		router, db := setupUserTestEnv()
+
+		email := fmt.Sprintf("verify%d@example.com", time.Now().UnixNano())
+		body, _ := json.Marshal(map[string]string{
+			"username": email, "password": "Password123", "first_name": "F", "last_name": "L",
+		})
+		req, _ := http.NewRequest("POST", "/v1/user/", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 201, w.Code)
+
+		// Registration must have issued a token
+		_, err := verify.Store.Lookup(context.Background(), email)
+		assert.NoError(t, err)
+
+		// Re-issue to learn the raw token (the one from registration only travels via SNS)
+		token, _ := verify.Store.Issue(context.Background(), email, time.Hour)
+
+		t.Run("should return 400 for a wrong token", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/v1/user/verifyEmail?email="+url.QueryEscape(email)+"&token=wrong", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 400, w.Code)
+		})
+
+		t.Run("should verify the account and return 200", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/v1/user/verifyEmail?email="+url.QueryEscape(email)+"&token="+token, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code)
+
+			var u models.User
+			db.Where("username = ?", email).First(&u)
+			assert.True(t, u.Verified)
+			assert.NotNil(t, u.VerifiedAt)
+		})
+
+		t.Run("should not accept the same token twice", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/v1/user/verifyEmail?email="+url.QueryEscape(email)+"&token="+token, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 400, w.Code)
+		})
+	})
+
+	// --- Resend Verification Tests ---
+	t.Run("POST /v1/user/verifyEmail/resend", func(t *testing.T) {
+		router, _ := setupUserTestEnv()
+
+		// Unknown address: answered with 202 without touching DynamoDB
+		email := fmt.Sprintf("resend%d@example.com", time.Now().UnixNano())
+		body, _ := json.Marshal(map[string]string{"username": email})
+
+		t.Run("should accept the first requests and then return 429", func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				req, _ := http.NewRequest("POST", "/v1/user/verifyEmail/resend", bytes.NewBuffer(body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, 202, w.Code)
+			}
+
+			req, _ := http.NewRequest("POST", "/v1/user/verifyEmail/resend", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 429, w.Code)
+			assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		})
+
+		t.Run("should return 400 for an invalid email", func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/v1/user/verifyEmail/resend", bytes.NewBufferString(`{"username":"not-an-email"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 400, w.Code)
+		})
 	})
 
 	// --- Protected Route Tests ---
@@ -78,7 +188,7 @@ func TestUserController(t *testing.T) {
 		password := "mySecretPass"
 		hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 		user := models.User{
-			Username: "get@example.com", Password: string(hashed), FirstName: "Get", LastName: "User",
+			Username: "get@example.com", Password: string(hashed), FirstName: "Get", LastName: "User", Verified: true,
 		}
 		db.Create(&user)
 
@@ -97,6 +207,19 @@ func TestUserController(t *testing.T) {
 			assert.Equal(t, 200, w.Code)
 		})
 
+		t.Run("should return 403 for an unverified account", func(t *testing.T) {
+			unverified := models.User{
+				Username: "unverified@example.com", Password: string(hashed), FirstName: "Not", LastName: "Verified",
+			}
+			db.Create(&unverified)
+
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/user/%d", unverified.ID), nil)
+			req.SetBasicAuth(unverified.Username, password)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 403, w.Code)
+		})
+
 		t.Run("should return 401 with Invalid Password", func(t *testing.T) {
 			req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/user/%d", user.ID), nil)
 			req.SetBasicAuth(user.Username, "wrongpassword")
@@ -112,9 +235,10 @@ func TestUserController(t *testing.T) {
 		password := "oldPass"
 		hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 		user := models.User{
-			Username: "put@example.com", Password: string(hashed), FirstName: "Old", LastName: "Name",
+			Username: "put@example.com", Password: string(hashed), FirstName: "Old", LastName: "Name", Verified: true,
 		}
 		db.Create(&user)
+		_, rawKey, _ := auth.CreateAPIKey(&user, "ci", []string{string(policy.ProductWrite)})
 
 		t.Run("should update and return 204", func(t *testing.T) {
 			updateData := map[string]string{
@@ -139,6 +263,11 @@ func TestUserController(t *testing.T) {
 			var updated models.User
 			db.First(&updated, user.ID)
 			assert.Equal(t, "Updated", updated.FirstName)
+
+			// The password changed, so keys minted with the old one are revoked
+			assert.NotEmpty(t, rawKey)
+			_, _, err := auth.AuthenticateAPIKey(rawKey)
+			assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
 		})
 	})
 }
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"my-project/db"
+	"my-project/verify"
+)
+
+func TestVerificationStore(t *testing.T) {
+	db.DB.Exec("DELETE FROM verification_tokens")
+
+	stores := map[string]verify.VerificationStore{
+		"memory":   verify.NewMemoryStore(),
+		"postgres": verify.NewPostgresStore(db.DB, verify.PurposeEmailVerification),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			t.Run("should store only a hash of the token", func(t *testing.T) {
+				token, err := store.Issue(ctx, "hash@example.com", time.Hour)
+				assert.NoError(t, err)
+
+				record, err := store.Lookup(ctx, "hash@example.com")
+				assert.NoError(t, err)
+				assert.NotEqual(t, token, record.TokenHash)
+				assert.NotContains(t, record.TokenHash, token)
+			})
+
+			t.Run("should reject a wrong token without consuming it", func(t *testing.T) {
+				token, _ := store.Issue(ctx, "wrong@example.com", time.Hour)
+
+				assert.ErrorIs(t, store.Consume(ctx, "wrong@example.com", "guess"), verify.ErrInvalidToken)
+				assert.NoError(t, store.Consume(ctx, "wrong@example.com", token))
+			})
+
+			t.Run("should reject an expired token", func(t *testing.T) {
+				token, _ := store.Issue(ctx, "expired@example.com", -time.Minute)
+				assert.ErrorIs(t, store.Consume(ctx, "expired@example.com", token), verify.ErrExpired)
+			})
+
+			t.Run("should redeem a token exactly once under concurrency", func(t *testing.T) {
+				token, _ := store.Issue(ctx, "race@example.com", time.Hour)
+
+				var wg sync.WaitGroup
+				var mu sync.Mutex
+				successes := 0
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if store.Consume(ctx, "race@example.com", token) == nil {
+							mu.Lock()
+							successes++
+							mu.Unlock()
+						}
+					}()
+				}
+				wg.Wait()
+
+				assert.Equal(t, 1, successes)
+			})
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// dynamoItem is the item layout of DDB_VERIFY_TABLE (partition key: email).
+// "ttl" is the table's TTL attribute, so DynamoDB also sweeps expired items.
+type dynamoItem struct {
+	Email     string `dynamodbav:"email"`
+	TokenHash string `dynamodbav:"token_hash"`
+	TTL       int64  `dynamodbav:"ttl"`
+}
+
+// DynamoStore keeps tokens in a DynamoDB table.
+// keyPrefix lets several purposes share one table without colliding on the email key.
+type DynamoStore struct {
+	client    *dynamodb.Client
+	table     string
+	keyPrefix string
+}
+
+// NewDynamoStore builds a DynamoDB client from the default AWS credential chain
+func NewDynamoStore(ctx context.Context, table, region, keyPrefix string) (*DynamoStore, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return &DynamoStore{client: dynamodb.NewFromConfig(cfg), table: table, keyPrefix: keyPrefix}, nil
+}
+
+func (s *DynamoStore) key(email string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"email": &types.AttributeValueMemberS{Value: s.keyPrefix + email},
+	}
+}
+
+func (s *DynamoStore) Issue(ctx context.Context, email string, ttl time.Duration) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	item, err := attributevalue.MarshalMap(dynamoItem{
+		Email:     s.keyPrefix + email,
+		TokenHash: hashToken(token),
+		TTL:       time.Now().Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      item,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *DynamoStore) Lookup(ctx context.Context, email string) (*Record, error) {
+	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            s.key(email),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	var item dynamoItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, err
+	}
+	return &Record{Email: email, TokenHash: item.TokenHash, ExpiresAt: time.Unix(item.TTL, 0)}, nil
+}
+
+// Consume checks the token, then deletes the item only if it still holds the same hash
+// and has not expired. Of two concurrent redemptions only one conditional delete can win.
+func (s *DynamoStore) Consume(ctx context.Context, email, token string) error {
+	record, err := s.Lookup(ctx, email)
+	if err != nil {
+		return err
+	}
+	if err := check(record, token); err != nil {
+		return err
+	}
+
+	_, err = s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(s.table),
+		Key:                 s.key(email),
+		ConditionExpression: aws.String("token_hash = :hash AND #ttl >= :now"),
+		// "ttl" is a DynamoDB reserved word
+		ExpressionAttributeNames: map[string]string{"#ttl": "ttl"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":hash": &types.AttributeValueMemberS{Value: record.TokenHash},
+			":now":  &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrInvalidToken
+	}
+	return err
+}
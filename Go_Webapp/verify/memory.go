@@ -0,0 +1,57 @@
+package verify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps tokens in a map. Used by the test suite and single-instance dev setups.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Issue(ctx context.Context, email string, ttl time.Duration) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[email] = Record{Email: email, TokenHash: hashToken(token), ExpiresAt: time.Now().Add(ttl)}
+	return token, nil
+}
+
+func (s *MemoryStore) Lookup(ctx context.Context, email string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[email]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+// Consume checks and deletes under one lock, so concurrent redemptions cannot both succeed
+func (s *MemoryStore) Consume(ctx context.Context, email, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[email]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := check(&record, token); err != nil {
+		return err
+	}
+
+	delete(s.records, email)
+	return nil
+}
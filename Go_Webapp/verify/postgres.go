@@ -0,0 +1,94 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"my-project/models"
+)
+
+const (
+	// PurposeEmailVerification namespaces registration tokens in the shared table
+	PurposeEmailVerification = "email_verification"
+
+	// PurposePasswordReset namespaces password reset tokens in the shared table
+	PurposePasswordReset = "password_reset"
+)
+
+// PostgresStore keeps tokens in the verification_tokens table, one row per (purpose, email)
+type PostgresStore struct {
+	db      *gorm.DB
+	purpose string
+}
+
+func NewPostgresStore(database *gorm.DB, purpose string) *PostgresStore {
+	return &PostgresStore{db: database, purpose: purpose}
+}
+
+func (s *PostgresStore) Issue(ctx context.Context, email string, ttl time.Duration) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	row := models.VerificationToken{
+		Purpose:   s.purpose,
+		Email:     email,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: time.Now(),
+	}
+
+	// Upsert: a new token replaces the outstanding one
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "purpose"}, {Name: "email"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "expires_at", "created_at"}),
+	}).Create(&row).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *PostgresStore) Lookup(ctx context.Context, email string) (*Record, error) {
+	return s.lookup(s.db.WithContext(ctx), email)
+}
+
+func (s *PostgresStore) lookup(tx *gorm.DB, email string) (*Record, error) {
+	var row models.VerificationToken
+	err := tx.Where("purpose = ? AND email = ?", s.purpose, email).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Record{Email: row.Email, TokenHash: row.TokenHash, ExpiresAt: row.ExpiresAt}, nil
+}
+
+// Consume locks the row (SELECT ... FOR UPDATE), checks it and deletes it in one transaction
+func (s *PostgresStore) Consume(ctx context.Context, email, token string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record, err := s.lookup(tx.Clauses(clause.Locking{Strength: "UPDATE"}), email)
+		if err != nil {
+			return err
+		}
+		if err := check(record, token); err != nil {
+			return err
+		}
+
+		result := tx.Where("purpose = ? AND email = ? AND token_hash = ?", s.purpose, email, record.TokenHash).
+			Delete(&models.VerificationToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrInvalidToken
+		}
+		return nil
+	})
+}
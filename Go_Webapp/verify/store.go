@@ -0,0 +1,145 @@
+package verify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"my-project/db"
+	"my-project/env"
+	"my-project/logs"
+)
+
+var (
+	// ErrNotFound means no token is outstanding for the email
+	ErrNotFound = errors.New("verify: no token for email")
+
+	// ErrInvalidToken means the presented token does not match the outstanding one
+	ErrInvalidToken = errors.New("verify: invalid token")
+
+	// ErrExpired means the token matched but its TTL has passed
+	ErrExpired = errors.New("verify: token expired")
+)
+
+// Record is an outstanding token for one email address.
+// Only the keyed hash of the token is ever stored.
+type Record struct {
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+// VerificationStore issues and redeems one-time tokens keyed by email.
+// Issuing a new token replaces any outstanding one for the same address.
+// Implementations must make Consume atomic: a token can be redeemed at most once.
+type VerificationStore interface {
+	// Issue generates a new token valid for ttl and returns it
+	Issue(ctx context.Context, email string, ttl time.Duration) (string, error)
+
+	// Lookup returns the outstanding record, or ErrNotFound
+	Lookup(ctx context.Context, email string) (*Record, error)
+
+	// Consume redeems the token: it returns nil only if the token matches and
+	// has not expired, and removes it so it cannot be used again
+	Consume(ctx context.Context, email, token string) error
+}
+
+// Store is the global store for email verification tokens
+var Store VerificationStore
+
+// ResetStore is the global store for password reset tokens
+var ResetStore VerificationStore
+
+// InitializeStore selects the backend from VERIFY_STORE: dynamodb (default), postgres or memory.
+// Both stores share the backend; the purpose keeps their tokens apart.
+func InitializeStore() {
+	// Load VERIFY_TOKEN_SECRET now, so a missing secret stops the server at startup
+	hashKey()
+
+	Store = newStore(PurposeEmailVerification)
+	ResetStore = newStore(PurposePasswordReset)
+
+	log.Println("Verification token store has been initialized!")
+}
+
+func newStore(purpose string) VerificationStore {
+	switch os.Getenv("VERIFY_STORE") {
+	case "memory":
+		return NewMemoryStore()
+
+	case "postgres":
+		return NewPostgresStore(db.DB, purpose)
+
+	default:
+		// Registration items keep their historical unprefixed key in DDB_VERIFY_TABLE
+		keyPrefix := ""
+		if purpose != PurposeEmailVerification {
+			keyPrefix = purpose + "#"
+		}
+
+		store, err := NewDynamoStore(context.TODO(), os.Getenv("DDB_VERIFY_TABLE"), os.Getenv("AWS_REGION"), keyPrefix)
+		if err != nil {
+			log.Fatalf("Verification store initialization failed: %v", err)
+		}
+		return store
+	}
+}
+
+// newToken returns 32 random bytes encoded as URL-safe base64
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+var (
+	secretOnce sync.Once
+	secret     []byte
+)
+
+// hashKey reads VERIFY_TOKEN_SECRET once. Outside development and tests (see env.IsDev) it must be set.
+// In development a random key is generated, which invalidates outstanding links on restart.
+func hashKey() []byte {
+	secretOnce.Do(func() {
+		if value := env.String("VERIFY_TOKEN_SECRET", ""); value != "" {
+			secret = []byte(value)
+			return
+		}
+		if !env.IsDev() {
+			logs.Fatal("VERIFY_TOKEN_SECRET is not set (required unless GO_ENV is test or development)")
+		}
+		logs.Warn("VERIFY_TOKEN_SECRET is not set, using a random per-process key")
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	})
+	return secret
+}
+
+// hashToken returns the hex HMAC-SHA256 of the token. Someone who can read the table
+// still cannot forge a link without the key.
+func hashToken(token string) string {
+	mac := hmac.New(sha256.New, hashKey())
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// check compares a stored record against a presented token in constant time
+func check(record *Record, token string) error {
+	if !hmac.Equal([]byte(record.TokenHash), []byte(hashToken(token))) {
+		return ErrInvalidToken
+	}
+	if record.ExpiresAt.Before(time.Now()) {
+		return ErrExpired
+	}
+	return nil
+}